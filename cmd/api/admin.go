@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+type UpdateUserRolePayload struct {
+	Role string `json:"role" validate:"required,max=255"`
+}
+
+// listRolesHandler godoc
+//
+//	@Summary		List roles
+//	@Description	List the roles users can have, lowest level first. Admins only.
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200	{object}	[]store.Role
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/roles [get]
+func (app *application) listRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := app.store.Roles.List(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, roles); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// updateUserRoleHandler godoc
+//
+//	@Summary		Change a user's role
+//	@Description	Give a user another role by name. Admins only, and not for their own account.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"User ID"
+//	@Param			payload	body		UpdateUserRolePayload	true	"Role name"
+//	@Success		200		{object}	store.Role
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{id}/role [patch]
+func (app *application) updateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload UpdateUserRolePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// an admin demoting themselves could leave nobody able to undo it
+	admin := getUserFromContext(r)
+	if admin.ID == userID {
+		app.badRequestResponse(w, r, errors.New("you can not change your own role"))
+		return
+	}
+
+	ctx := r.Context()
+	role, err := app.store.Roles.GetByName(ctx, payload.Role)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, errors.New("unknown role: "+payload.Role))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.Users.UpdateRole(ctx, userID, role.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, role); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("user role changed", "user", userID, "role", role.Name, "by", admin.ID)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+const (
+	defaultTrendMonths = 6
+	maxTrendMonths     = 60
+)
+
+type AnalyticsSummaryResponse struct {
+	Month string `json:"month"`
+	store.AnalyticsSummary
+}
+
+// parseAnalyticsScope reads the scope of an analytics request. Without scope
+// the user's personal transactions are used; scope=group selects the groups of
+// the user, narrowed to one with groupId.
+func parseAnalyticsScope(r *http.Request) (store.AnalyticsScope, error) {
+	user := getUserFromContext(r)
+	scope := store.AnalyticsScope{UserID: user.ID}
+	queryParams := r.URL.Query()
+
+	switch queryParams.Get("scope") {
+	case "", "personal":
+		if queryParams.Get("groupId") != "" {
+			return scope, errors.New("groupId requires scope=group")
+		}
+		return scope, nil
+	case "group":
+		scope.Group = true
+	default:
+		return scope, fmt.Errorf("invalid scope: %s", queryParams.Get("scope"))
+	}
+
+	if groupID := queryParams.Get("groupId"); groupID != "" {
+		groupIDInt, err := strconv.ParseInt(groupID, 10, 64)
+		if err != nil || groupIDInt <= 0 {
+			return scope, fmt.Errorf("invalid group ID: %s", groupID)
+		}
+		scope.GroupID = groupIDInt
+	}
+	return scope, nil
+}
+
+// parseAnalyticsMonth reads the month query parameter (YYYY-MM) and returns
+// its first day, defaulting to the current month.
+func parseAnalyticsMonth(r *http.Request) (time.Time, error) {
+	month := r.URL.Query().Get("month")
+	if month == "" {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	start, err := time.Parse(monthLayout, month)
+	if err != nil {
+		return start, fmt.Errorf("invalid month, expected YYYY-MM: %s", month)
+	}
+	return start, nil
+}
+
+// analyticsRequest parses the scope and month shared by the analytics
+// endpoints and answers the request itself if they are invalid or the user is
+// not a member of the requested group.
+func (app *application) analyticsRequest(w http.ResponseWriter, r *http.Request) (store.AnalyticsScope, time.Time, bool) {
+	month, err := parseAnalyticsMonth(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return store.AnalyticsScope{}, month, false
+	}
+	scope, err := parseAnalyticsScope(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return scope, month, false
+	}
+	if scope.GroupID != 0 {
+		isMember, err := app.store.Groups.IsMember(r.Context(), scope.GroupID, scope.UserID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return scope, month, false
+		}
+		if !isMember {
+			app.unauthorizedErrorResponse(w, r, errors.New("user is not a member of this group"))
+			return scope, month, false
+		}
+	}
+	return scope, month, true
+}
+
+// analyticsSummaryHandler godoc
+//
+//	@Summary		Get income and expense totals
+//	@Description	Total income, expenses and balance of a month
+//	@Tags			analytics
+//	@Produce		json
+//	@Param			month	query		string	false	"Month (YYYY-MM), defaults to the current month"
+//	@Param			scope	query		string	false	"personal (default) or group"
+//	@Param			groupId	query		int		false	"Only this group, with scope=group"
+//	@Success		200		{object}	AnalyticsSummaryResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/analytics/summary [get]
+func (app *application) analyticsSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	scope, month, ok := app.analyticsRequest(w, r)
+	if !ok {
+		return
+	}
+
+	summary, err := app.store.Analytics.Summary(r.Context(), scope, month, month.AddDate(0, 1, 0))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := AnalyticsSummaryResponse{Month: month.Format(monthLayout), AnalyticsSummary: *summary}
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// analyticsTrendHandler godoc
+//
+//	@Summary		Get the monthly trend
+//	@Description	Income, expenses and savings of the last N months up to and including month, oldest first
+//	@Tags			analytics
+//	@Produce		json
+//	@Param			months	query		int		false	"Number of months (default 6, at most 60)"
+//	@Param			month	query		string	false	"Last month (YYYY-MM), defaults to the current month"
+//	@Param			scope	query		string	false	"personal (default) or group"
+//	@Param			groupId	query		int		false	"Only this group, with scope=group"
+//	@Success		200		{object}	[]store.MonthlyTrend
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/analytics/trend [get]
+func (app *application) analyticsTrendHandler(w http.ResponseWriter, r *http.Request) {
+	months := defaultTrendMonths
+	if value := r.URL.Query().Get("months"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxTrendMonths {
+			app.badRequestResponse(w, r, fmt.Errorf("months must be between 1 and %d", maxTrendMonths))
+			return
+		}
+		months = n
+	}
+
+	scope, month, ok := app.analyticsRequest(w, r)
+	if !ok {
+		return
+	}
+
+	end := month.AddDate(0, 1, 0)
+	trend, err := app.store.Analytics.Trend(r.Context(), scope, end.AddDate(0, -months, 0), end)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, trend); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// analyticsByCategoryHandler godoc
+//
+//	@Summary		Get expenses by category
+//	@Description	Expenses of a month per top-level category, with sub-categories rolled up into their parent, largest first
+//	@Tags			analytics
+//	@Produce		json
+//	@Param			month	query		string	false	"Month (YYYY-MM), defaults to the current month"
+//	@Param			scope	query		string	false	"personal (default) or group"
+//	@Param			groupId	query		int		false	"Only this group, with scope=group"
+//	@Success		200		{object}	[]store.CategoryAmount
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/analytics/by-category [get]
+func (app *application) analyticsByCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	scope, month, ok := app.analyticsRequest(w, r)
+	if !ok {
+		return
+	}
+
+	amounts, err := app.store.Analytics.ByCategory(r.Context(), scope, month, month.AddDate(0, 1, 0))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, amounts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// analyticsDailyHandler godoc
+//
+//	@Summary		Get daily spending
+//	@Description	Expenses of every day of a month
+//	@Tags			analytics
+//	@Produce		json
+//	@Param			month	query		string	false	"Month (YYYY-MM), defaults to the current month"
+//	@Param			scope	query		string	false	"personal (default) or group"
+//	@Param			groupId	query		int		false	"Only this group, with scope=group"
+//	@Success		200		{object}	[]store.DailyAmount
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/analytics/daily [get]
+func (app *application) analyticsDailyHandler(w http.ResponseWriter, r *http.Request) {
+	scope, month, ok := app.analyticsRequest(w, r)
+	if !ok {
+		return
+	}
+
+	days, err := app.store.Analytics.Daily(r.Context(), scope, month)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, days); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
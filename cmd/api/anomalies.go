@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+// flagAnomaly checks a transaction that was just stored and records whether it
+// is unusual. A failed check is logged and does not fail the request, the
+// transaction is stored either way.
+func (app *application) flagAnomaly(ctx context.Context, transaction *store.Transaction) {
+	if err := app.store.Transactions.DetectAnomaly(ctx, transaction, app.config.anomalies); err != nil {
+		app.logger.Errorw("failed to check transaction for anomalies", "transaction", transaction.ID, "error", err)
+		return
+	}
+	if transaction.Anomaly != nil {
+		app.logger.Infow("transaction flagged", "transaction", transaction.ID, "reason", transaction.Anomaly.Reason)
+	}
+}
+
+// flagAnomalies checks imported or scheduled transactions in the background,
+// so large batches are not held up. Their flags show up on later reads.
+func (app *application) flagAnomalies(transactions []*store.Transaction) {
+	if len(transactions) == 0 {
+		return
+	}
+	app.background(func() {
+		for _, transaction := range transactions {
+			app.flagAnomaly(context.Background(), transaction)
+		}
+	})
+}
+
+// listAnomaliesHandler godoc
+//
+//	@Summary		List unusual transactions
+//	@Description	List the authenticated user's transactions that were flagged when they were stored, as amount outliers for their category or as large first payments to a new merchant, newest first
+//	@Tags			analytics
+//	@Produce		json
+//	@Param			startDate	query		string	false	"Start transaction date (YYYY-MM-DD, inclusive)"
+//	@Param			endDate		query		string	false	"End transaction date (YYYY-MM-DD, inclusive)"
+//	@Success		200			{object}	[]store.Transaction
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/analytics/anomalies [get]
+func (app *application) listAnomaliesHandler(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	startDate, endDate := queryParams.Get("startDate"), queryParams.Get("endDate")
+	for _, date := range []string{startDate, endDate} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid date, expected YYYY-MM-DD: %s", date))
+			return
+		}
+	}
+
+	user := getUserFromContext(r)
+	transactions, err := app.store.Transactions.ListAnomalies(r.Context(), user.ID, startDate, endDate)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, transactions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
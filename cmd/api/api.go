@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -30,17 +31,31 @@ type application struct {
 	logger        *zap.SugaredLogger
 	mailer        mail.MailerClient
 	rateLimiter   ratelimiter.RateLimiter
+	// wg tracks the goroutines started with background so shutdown can wait for them.
+	wg sync.WaitGroup
 }
 
 type config struct {
-	addr        string
-	db          dbConfig
-	env         string
-	apiURL      string
-	frontendURL string
-	mail        mailConfig
-	auth        authConfig
-	rateLimiter ratelimiter.RateLimiterConfig
+	addr         string
+	db           dbConfig
+	env          string
+	apiURL       string
+	frontendURL  string
+	mail         mailConfig
+	auth         authConfig
+	rateLimiter  ratelimiter.RateLimiterConfig
+	scheduler    schedulerConfig
+	budgetAlerts budgetAlertConfig
+	anomalies    store.AnomalyRules
+}
+
+type budgetAlertConfig struct {
+	// thresholds are the percentages of a budget's limit that trigger an alert, ascending.
+	thresholds []int
+}
+
+type schedulerConfig struct {
+	interval time.Duration
 }
 
 type dbConfig struct {
@@ -73,9 +88,10 @@ type authConfig struct {
 }
 
 type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	iss    string
+	secret     string
+	exp        time.Duration
+	refreshExp time.Duration
+	iss        string
 }
 
 type basicConfig struct {
@@ -99,62 +115,204 @@ func (app *application) mount() http.Handler {
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
 
-	// Set a timeout value on the request context (ctx), that will signal
-	// through ctx.Done() that the request has timed out and further
-	// processing should be stopped.
-	r.Use(middleware.Timeout(60 * time.Second))
 	if app.config.rateLimiter.Enabled {
 		r.Use(app.rateLimitMiddleware)
 	}
 	r.Route("/api/v1", func(r chi.Router) {
-		// Operations
-		r.Get("/health", app.healthCheckHandler)
-
-		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
-		fmt.Println("docsURL", app.config.addr)
-		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
-
-		r.Route("/transactions", func(r chi.Router) {
-			r.Use(app.AuthTokenMiddleware)
-			r.Post("/", app.createTransactionHandler)
-			r.Get("/", app.listTransactionsHandler)
-			r.Route("/{id}", func(r chi.Router) {
-				r.Use(app.transactionContextMiddleware)
-				r.Get("/", app.checkTransactionOwnership(app.getTransactionByIDHandler))
-				r.Delete("/", app.checkTransactionOwnership(app.deleteTransactionByIDHandler))
-				r.Patch("/", app.checkTransactionOwnership(app.updateTransactionByIDHandler))
+		// Exports stream for as long as they take and keep extending their own
+		// write deadline, so they are mounted outside the request timeout.
+		r.With(app.tokenScope("transactions"), app.AuthTokenMiddleware).Get("/transactions/export", app.exportTransactionsHandler)
+
+		r.Group(func(r chi.Router) {
+			// Set a timeout value on the request context (ctx), that will signal
+			// through ctx.Done() that the request has timed out and further
+			// processing should be stopped.
+			r.Use(middleware.Timeout(60 * time.Second))
+
+			// Operations
+			r.Get("/health", app.healthCheckHandler)
+
+			docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
+			fmt.Println("docsURL", app.config.addr)
+			r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
+
+			r.Route("/transactions", func(r chi.Router) {
+				r.Use(app.tokenScope("transactions"))
+				r.Use(app.AuthTokenMiddleware)
+				r.Post("/", app.createTransactionHandler)
+				r.Get("/", app.listTransactionsHandler)
+				r.Post("/import", app.importTransactionsHandler)
+				r.Post("/import/confirm", app.confirmImportTransactionsHandler)
+				r.Route("/{id}", func(r chi.Router) {
+					r.Use(app.transactionContextMiddleware)
+					r.Get("/", app.checkTransactionOwnership(app.getTransactionByIDHandler))
+					r.Delete("/", app.checkTransactionOwnership(app.deleteTransactionByIDHandler))
+					r.Patch("/", app.checkTransactionOwnership(app.updateTransactionByIDHandler))
+				})
+			})
+			r.Route("/categories", func(r chi.Router) {
+				r.Use(app.tokenScope("categories"))
+				r.Use(app.AuthTokenMiddleware)
+				r.Get("/", app.listCategoriesHandler)
+				r.Post("/", app.createCategoryHandler)
+				r.Get("/totals", app.categoryTotalsHandler)
+				r.Route("/{id}", func(r chi.Router) {
+					r.Use(app.categoryContextMiddleware)
+					r.Patch("/", app.checkCategoryOwnership(app.updateCategoryHandler))
+					r.Delete("/", app.checkCategoryOwnership(app.deleteCategoryHandler))
+				})
+			})
+			r.Route("/budgets", func(r chi.Router) {
+				r.Use(app.tokenScope("budgets"))
+				r.Use(app.AuthTokenMiddleware)
+				r.Post("/", app.createBudgetHandler)
+				r.Get("/", app.listBudgetsHandler)
+				r.Get("/{month:\\d{4}-\\d{2}}", app.getBudgetMonthHandler)
+				r.Post("/{month:\\d{4}-\\d{2}}/rollover", app.rolloverBudgetsHandler)
+				r.Post("/transfers", app.transferBudgetHandler)
+				r.Route("/{id:\\d+}", func(r chi.Router) {
+					r.Use(app.budgetContextMiddleware)
+					r.Get("/", app.checkBudgetOwnership(app.getBudgetHandler))
+					r.Get("/ledger", app.checkBudgetOwnership(app.listBudgetAllocationsHandler))
+					r.Patch("/", app.checkBudgetOwnership(app.updateBudgetHandler))
+					r.Delete("/", app.checkBudgetOwnership(app.deleteBudgetHandler))
+				})
+			})
+			r.Route("/analytics", func(r chi.Router) {
+				r.Use(app.tokenScope("analytics"))
+				r.Use(app.AuthTokenMiddleware)
+				r.Get("/summary", app.analyticsSummaryHandler)
+				r.Get("/trend", app.analyticsTrendHandler)
+				r.Get("/by-category", app.analyticsByCategoryHandler)
+				r.Get("/daily", app.analyticsDailyHandler)
+				r.Get("/forecast", app.analyticsForecastHandler)
+				r.Get("/anomalies", app.listAnomaliesHandler)
+				r.Get("/compare", app.comparePeriodsHandler)
+			})
+			r.Route("/goals", func(r chi.Router) {
+				r.Use(app.tokenScope("goals"))
+				r.Use(app.AuthTokenMiddleware)
+				r.Post("/", app.createGoalHandler)
+				r.Get("/", app.listGoalsHandler)
+				r.Route("/{id}", func(r chi.Router) {
+					r.Use(app.goalContextMiddleware)
+					r.Get("/", app.checkGoalOwnership(app.getGoalHandler))
+					r.Patch("/", app.checkGoalOwnership(app.updateGoalHandler))
+					r.Delete("/", app.checkGoalOwnership(app.deleteGoalHandler))
+					r.Get("/contributions", app.checkGoalOwnership(app.listGoalContributionsHandler))
+					r.Post("/contributions", app.checkGoalOwnership(app.createGoalContributionHandler))
+					r.Delete("/contributions/{contributionId}", app.checkGoalOwnership(app.deleteGoalContributionHandler))
+				})
+			})
+			r.Route("/recurring-transactions", func(r chi.Router) {
+				r.Use(app.tokenScope("recurring"))
+				r.Use(app.AuthTokenMiddleware)
+				r.Post("/", app.createRecurringTransactionHandler)
+				r.Get("/", app.listRecurringTransactionsHandler)
+				r.Route("/{id}", func(r chi.Router) {
+					r.Use(app.recurringTransactionContextMiddleware)
+					r.Get("/", app.checkRecurringTransactionOwnership(app.getRecurringTransactionHandler))
+					r.Patch("/", app.checkRecurringTransactionOwnership(app.updateRecurringTransactionHandler))
+					r.Delete("/", app.checkRecurringTransactionOwnership(app.deleteRecurringTransactionHandler))
+				})
+			})
+			r.Route("/groups", func(r chi.Router) {
+				r.Use(app.tokenScope("groups"))
+				r.Use(app.AuthTokenMiddleware)
+				r.Post("/", app.createGroupHandler)
+				r.Get("/", app.listGroupsHandler)
+				r.Route("/{id}", func(r chi.Router) {
+					r.Use(app.groupContextMiddleware)
+					r.Get("/", app.checkGroupMembership(app.getGroupHandler))
+					r.Patch("/", app.checkGroupMembership(app.updateGroupHandler))
+					r.Delete("/", app.checkGroupOwnership(app.deleteGroupHandler))
+					r.Post("/members", app.checkGroupMembership(app.addGroupMemberHandler))
+					r.Delete("/members/{userID}", app.checkGroupMembership(app.removeGroupMemberHandler))
+					r.Get("/balances", app.checkGroupMembership(app.getGroupBalancesHandler))
+					r.Post("/settlements", app.checkGroupMembership(app.createSettlementHandler))
+					r.Route("/transactions", func(r chi.Router) {
+						r.Post("/", app.checkGroupMembership(app.createGroupTransactionHandler))
+						r.Get("/", app.checkGroupMembership(app.listGroupTransactionsHandler))
+						r.Route("/{transactionID}", func(r chi.Router) {
+							r.Use(app.groupTransactionContextMiddleware)
+							r.Get("/", app.checkGroupMembership(app.getGroupTransactionHandler))
+							r.Patch("/", app.checkGroupMembership(app.updateGroupTransactionHandler))
+							r.Delete("/", app.checkGroupMembership(app.deleteGroupTransactionHandler))
+						})
+					})
+				})
+			})
+
+			r.Route("/users", func(r chi.Router) {
+				r.Put("/activate/{token}", app.activateUserHandler)
+				r.Route("/", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					// 	r.Put("/activate/{token}", app.activateUserHandler)
+					r.Get("/token", app.getUserByTokenHandler)
+					r.Route("/mfa", func(r chi.Router) {
+						r.Get("/", app.getMFAStatusHandler)
+						r.Post("/totp", app.enrollTOTPHandler)
+						r.Post("/totp/verify", app.verifyTOTPHandler)
+						r.Delete("/totp", app.disableTOTPHandler)
+					})
+
+				})
 			})
-		})
-		r.Route("/categories", func(r chi.Router) {
-			r.Use(app.AuthTokenMiddleware)
-			r.Get("/", app.listCategoriesHandler)
-			// r.Post("/", app.createCategoryHandler)
-		})
 
-		r.Route("/users", func(r chi.Router) {
-			r.Put("/activate/{token}", app.activateUserHandler)
-			r.Route("/", func(r chi.Router) {
+			r.Route("/admin", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
-				// 	r.Put("/activate/{token}", app.activateUserHandler)
-				r.Get("/token", app.getUserByTokenHandler)
+				r.Use(app.requireRole(store.RoleLevelAdmin))
+				r.Get("/roles", app.listRolesHandler)
+				r.Patch("/users/{id:\\d+}/role", app.updateUserRoleHandler)
+			})
 
+			r.Route("/tokens", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Post("/", app.createPersonalAccessTokenHandler)
+				r.Get("/", app.listPersonalAccessTokensHandler)
+				r.Delete("/{id:\\d+}", app.deletePersonalAccessTokenHandler)
+			})
+
+			r.Route("/sessions", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Get("/", app.listSessionsHandler)
+				r.Delete("/current", app.logoutHandler)
+				r.Delete("/others", app.logoutOtherSessionsHandler)
+				r.Delete("/{id}", app.revokeSessionHandler)
 			})
-		})
 
-		// Public routes
-		r.Route("/auth", func(r chi.Router) {
-			r.Post("/register", app.registerUserHandler)
-			r.Post("/login", app.loginUserHandler)
-			r.Get("/validate-invitation-token/{token}", app.validateUserInvitationTokenHandler)
-			r.Post("/forgot-password", app.forgotPasswordHandler)
-			r.Get("/validate-reset-token/{token}", app.validateResetPasswordTokenHandler)
-			r.Put("/reset-password", app.resetPasswordHandler)
+			// Public routes
+			r.Route("/auth", func(r chi.Router) {
+				r.Post("/register", app.registerUserHandler)
+				r.Post("/login", app.loginUserHandler)
+				r.Post("/refresh", app.refreshTokenHandler)
+				r.Post("/mfa", app.mfaLoginHandler)
+				r.Get("/validate-invitation-token/{token}", app.validateUserInvitationTokenHandler)
+				r.Post("/forgot-password", app.forgotPasswordHandler)
+				r.Get("/validate-reset-token/{token}", app.validateResetPasswordTokenHandler)
+				r.Put("/reset-password", app.resetPasswordHandler)
+			})
 		})
 	})
 
 	return r
 }
 
+// background runs fn in a goroutine that shutdown waits for. A panic in fn is
+// logged instead of crashing the server.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Errorw("background task panicked", "error", err)
+			}
+		}()
+		fn()
+	}()
+}
+
 func (app *application) run(mux http.Handler) error {
 	// Docs
 	docs.SwaggerInfo.Version = version
@@ -171,6 +329,19 @@ func (app *application) run(mux http.Handler) error {
 
 	shutdown := make(chan error)
 
+	// Background jobs run until the server has shut down.
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		app.runRecurringScheduler(jobsCtx)
+	}()
+	defer func() {
+		stopJobs()
+		<-jobsDone
+		app.wg.Wait()
+	}()
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 
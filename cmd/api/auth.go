@@ -131,21 +131,27 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 type LoginUserPayload struct {
 	Email    string `json:"email" validate:"required,email,max=255"`
 	Password string `json:"password" validate:"required,min=6,max=72"`
+	// Device names the session in the session list, e.g. "Work laptop".
+	Device string `json:"device" validate:"max=100"`
 }
 
+// LoginUserResponse carries a short-lived access token and the refresh token
+// that renews it through /auth/refresh.
 type LoginUserResponse struct {
-	Token string `json:"token"`
+	Token        string    `json:"token"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	RefreshToken string    `json:"refreshToken"`
 }
 
 // loginUserHandler godoc
 //
 //	@Summary		User login
-//	@Description	User login
+//	@Description	User login. Users with two-factor authentication get a challenge token to complete the login at /auth/mfa
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
 //	@Param			payload	body		LoginUserPayload	true	"Login user payload"
-//	@Success		200		{object}	LoginUserResponse
+//	@Success		200		{object}	LoginUserResponse	"Logged in, or an MFAChallengeResponse when two-factor authentication is enabled"
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
 //	@Failure		500		{object}	error
@@ -181,29 +187,185 @@ func (app *application) loginUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	ctx := r.Context()
+	userTOTP, err := app.store.MFA.GetTOTP(ctx, user.ID)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if userTOTP != nil && userTOTP.Enabled() {
+		challenge, err := app.mfaChallengeToken(ctx, user, payload.Device)
+		if err != nil {
+			app.logger.Errorw("failed to generate mfa challenge", "error", err)
+			app.internalServerError(w, r, err)
+			return
+		}
+		if err := app.jsonResponse(w, http.StatusOK, challenge); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		app.logger.Infow("mfa challenge issued", "user", user.ID)
+		return
+	}
+
+	response, err := app.startSession(r, user, payload.Device)
+	if err != nil {
+		app.logger.Errorw("failed to start session", "error", err)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.logger.Errorw("failed to write response", "error", err)
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("user logged in", "user", user.ID, "email", user.Email)
+}
+
+// startSession records a new session for the user and issues its first access
+// and refresh tokens.
+func (app *application) startSession(r *http.Request, user *store.User, device string) (*LoginUserResponse, error) {
+	ctx := r.Context()
+	// every login starts a session, which its refresh tokens belong to
+	session := &store.Session{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Device:    device,
+		IPAddress: r.RemoteAddr,
+		UserAgent: r.UserAgent(),
+		ExpiresAt: time.Now().Add(app.config.auth.token.refreshExp),
+	}
+	if err := app.store.Sessions.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	token, expiresAt, err := app.accessToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	plainRefreshToken, refreshTokenHash := newOpaqueToken()
+	refreshToken := &store.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  session.ID,
+		TokenHash: refreshTokenHash,
+		ExpiresAt: session.ExpiresAt,
+	}
+	if err := app.store.RefreshTokens.Create(ctx, refreshToken); err != nil {
+		return nil, err
+	}
+
+	return &LoginUserResponse{Token: token, ExpiresAt: expiresAt, RefreshToken: plainRefreshToken}, nil
+}
+
+// accessToken signs a short-lived access token for the user's session and
+// returns it with its expiry. The session ID is the token's jti, which
+// AuthTokenMiddleware checks so revoked sessions lose access right away.
+func (app *application) accessToken(user *store.User, sessionID string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(app.config.auth.token.exp)
 	claims := jwt.MapClaims{
 		"sub":  user.ID,
 		"iss":  app.config.auth.token.iss,
 		"aud":  app.config.auth.token.iss,
-		"exp":  time.Now().Add(app.config.auth.token.exp).Unix(),
-		"iat":  time.Now().Unix(),
+		"exp":  expiresAt.Unix(),
+		"iat":  now.Unix(),
 		"role": user.Role.Name,
-		"nbf":  time.Now().Unix(),
+		"nbf":  now.Unix(),
+		"jti":  sessionID,
 	}
 
 	token, err := app.authenticator.GenerateToken(claims)
+	return token, expiresAt.Truncate(time.Second), err
+}
+
+// newOpaqueToken returns a random token for the client and the hash it is
+// stored under.
+func newOpaqueToken() (string, string) {
+	plainToken := uuid.New().String()
+	return plainToken, hashToken(plainToken)
+}
+
+func hashToken(plainToken string) string {
+	hash := sha256.Sum256([]byte(plainToken))
+	return hex.EncodeToString(hash[:])
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refreshToken" validate:"required,max=255"`
+}
+
+// refreshTokenHandler godoc
+//
+//	@Summary		Refresh access token
+//	@Description	Exchange a refresh token for a new access token and a new refresh token. The old refresh token stops working; presenting it again revokes every refresh token issued since the login it came from
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token payload"
+//	@Success		200		{object}	LoginUserResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/auth/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	plainRefreshToken, refreshTokenHash := newOpaqueToken()
+	refreshToken := &store.RefreshToken{
+		TokenHash: refreshTokenHash,
+		ExpiresAt: time.Now().Add(app.config.auth.token.refreshExp),
+	}
+	if err := app.store.RefreshTokens.Rotate(ctx, hashToken(payload.RefreshToken), refreshToken); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, errors.New("invalid refresh token"))
+		case store.ErrRefreshTokenExpired, store.ErrRefreshTokenRevoked:
+			app.unauthorizedErrorResponse(w, r, err)
+		case store.ErrRefreshTokenReused:
+			app.logger.Warnw("refresh token reused, token family revoked", "error", err)
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, refreshToken.UserID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, errors.New("user not found"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	token, expiresAt, err := app.accessToken(user, refreshToken.FamilyID)
 	if err != nil {
 		app.logger.Errorw("failed to generate token", "error", err)
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, LoginUserResponse{Token: token}); err != nil {
-		app.logger.Errorw("failed to write response", "error", err)
+	response := LoginUserResponse{Token: token, ExpiresAt: expiresAt, RefreshToken: plainRefreshToken}
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
-	app.logger.Infow("user logged in", "user", user.ID, "email", user.Email)
+	app.logger.Infow("access token refreshed", "user", user.ID)
 }
 
 // valudateUserInvitationTokenHandler godoc
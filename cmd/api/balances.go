@@ -0,0 +1,303 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+type MemberBalance struct {
+	UserID   int64   `json:"userId"`
+	Username string  `json:"username"`
+	Paid     float64 `json:"paid"`
+	Owed     float64 `json:"owed"`
+	// Net is positive when the member is owed money and negative when they owe money.
+	Net float64 `json:"net"`
+}
+
+type Transfer struct {
+	FromUserID   int64   `json:"fromUserId"`
+	FromUsername string  `json:"fromUsername"`
+	ToUserID     int64   `json:"toUserId"`
+	ToUsername   string  `json:"toUsername"`
+	Amount       float64 `json:"amount"`
+}
+
+type GroupBalancesResponse struct {
+	Balances  []MemberBalance `json:"balances"`
+	Transfers []Transfer      `json:"transfers"`
+}
+
+// computeGroupBalances works out every member's net position. Each expense counts
+// as paid by the member who created it and owed according to its splits, or
+// equally by the current members when it has none. Settlements are stored the
+// same way: the payer paid the amount and the receiver owes all of it.
+func computeGroupBalances(group *store.Group, transactions []store.GroupTransaction) []MemberBalance {
+	type position struct {
+		username string
+		paid     int64
+		owed     int64
+	}
+
+	positions := make(map[int64]*position)
+	memberIDs := make([]int64, 0, len(group.Members))
+	get := func(userID int64, username string) *position {
+		p, ok := positions[userID]
+		if !ok {
+			p = &position{username: username}
+			positions[userID] = p
+		}
+		return p
+	}
+	for _, member := range group.Members {
+		get(member.UserID, member.Username)
+		memberIDs = append(memberIDs, member.UserID)
+	}
+
+	for _, transaction := range transactions {
+		if transaction.TransactionType != "expense" && transaction.TransactionType != store.TransactionTypeSettlement {
+			continue
+		}
+
+		amount := toCents(transaction.Amount)
+		get(transaction.UserID, transaction.CreatedBy).paid += amount
+
+		if len(transaction.Splits) > 0 {
+			for _, split := range transaction.Splits {
+				get(split.UserID, split.Username).owed += toCents(split.Amount)
+			}
+			continue
+		}
+
+		weights := make([]float64, len(memberIDs))
+		for i := range weights {
+			weights[i] = 1
+		}
+		for i, cents := range allocateByWeight(amount, weights) {
+			positions[memberIDs[i]].owed += cents
+		}
+	}
+
+	balances := make([]MemberBalance, 0, len(positions))
+	for userID, p := range positions {
+		balances = append(balances, MemberBalance{
+			UserID:   userID,
+			Username: p.username,
+			Paid:     fromCents(p.paid),
+			Owed:     fromCents(p.owed),
+			Net:      fromCents(p.paid - p.owed),
+		})
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].Username < balances[j].Username
+	})
+	return balances
+}
+
+// simplifyDebts returns a minimal set of transfers that settles all balances by
+// repeatedly matching the largest debtor with the largest creditor.
+func simplifyDebts(balances []MemberBalance) []Transfer {
+	type party struct {
+		userID   int64
+		username string
+		cents    int64
+	}
+
+	var debtors, creditors []party
+	for _, b := range balances {
+		cents := toCents(b.Net)
+		switch {
+		case cents < 0:
+			debtors = append(debtors, party{b.UserID, b.Username, -cents})
+		case cents > 0:
+			creditors = append(creditors, party{b.UserID, b.Username, cents})
+		}
+	}
+	byAmount := func(parties []party) func(i, j int) bool {
+		return func(i, j int) bool {
+			if parties[i].cents != parties[j].cents {
+				return parties[i].cents > parties[j].cents
+			}
+			return parties[i].userID < parties[j].userID
+		}
+	}
+	sort.Slice(debtors, byAmount(debtors))
+	sort.Slice(creditors, byAmount(creditors))
+
+	transfers := []Transfer{}
+	for i, j := 0, 0; i < len(debtors) && j < len(creditors); {
+		amount := min(debtors[i].cents, creditors[j].cents)
+		transfers = append(transfers, Transfer{
+			FromUserID:   debtors[i].userID,
+			FromUsername: debtors[i].username,
+			ToUserID:     creditors[j].userID,
+			ToUsername:   creditors[j].username,
+			Amount:       fromCents(amount),
+		})
+
+		debtors[i].cents -= amount
+		creditors[j].cents -= amount
+		if debtors[i].cents == 0 {
+			i++
+		}
+		if creditors[j].cents == 0 {
+			j++
+		}
+	}
+	return transfers
+}
+
+func (app *application) getGroupBalances(r *http.Request, group *store.Group) (*GroupBalancesResponse, error) {
+	transactions, err := app.store.GroupTransactions.ListByGroup(r.Context(), group.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := computeGroupBalances(group, transactions)
+	return &GroupBalancesResponse{
+		Balances:  balances,
+		Transfers: simplifyDebts(balances),
+	}, nil
+}
+
+// getGroupBalancesHandler godoc
+//
+//	@Summary		Get group balances
+//	@Description	Get each member's net position in a group and the minimal set of transfers that settles it
+//	@Tags			groups
+//	@Produce		json
+//	@Param			id	path		int	true	"Group ID"
+//	@Success		200	{object}	GroupBalancesResponse
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/groups/{id}/balances [get]
+func (app *application) getGroupBalancesHandler(w http.ResponseWriter, r *http.Request) {
+	balances, err := app.getGroupBalances(r, getGroupFromContext(r))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, balances); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type CreateSettlementRequest struct {
+	// FromUserID defaults to the authenticated user.
+	FromUserID int64 `json:"fromUserId" validate:"omitempty,gt=0"`
+	ToUserID   int64 `json:"toUserId" validate:"required,gt=0"`
+	// Amount defaults to the outstanding debt between the two members.
+	Amount *float64 `json:"amount" validate:"omitempty,gt=0"`
+}
+
+// createSettlementHandler godoc
+//
+//	@Summary		Record a settlement
+//	@Description	Record a payment from one group member to another. Without an amount the outstanding debt between them is settled.
+//	@Tags			groups
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int						true	"Group ID"
+//	@Param			settlement	body		CreateSettlementRequest	true	"Settlement data"
+//	@Success		201			{object}	store.GroupTransaction
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/groups/{id}/settlements [post]
+func (app *application) createSettlementHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateSettlementRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	group := getGroupFromContext(r)
+	if payload.FromUserID == 0 {
+		payload.FromUserID = user.ID
+	}
+	if payload.FromUserID == payload.ToUserID {
+		app.badRequestResponse(w, r, errors.New("a member cannot settle with themselves"))
+		return
+	}
+	if payload.FromUserID != user.ID && payload.ToUserID != user.ID {
+		app.unauthorizedErrorResponse(w, r, errors.New("only the payer or the receiver can record a settlement"))
+		return
+	}
+
+	usernames := make(map[int64]string, len(group.Members))
+	for _, member := range group.Members {
+		usernames[member.UserID] = member.Username
+	}
+	for _, id := range []int64{payload.FromUserID, payload.ToUserID} {
+		if _, ok := usernames[id]; !ok {
+			app.badRequestResponse(w, r, fmt.Errorf("user %d is not a member of this group", id))
+			return
+		}
+	}
+
+	var amount float64
+	if payload.Amount != nil {
+		amount = *payload.Amount
+	} else {
+		balances, err := app.getGroupBalances(r, group)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		// the simplified transfers may route the debt through other members,
+		// so settle as much as the payer owes and the receiver is owed
+		nets := make(map[int64]int64, len(balances.Balances))
+		for _, balance := range balances.Balances {
+			nets[balance.UserID] = toCents(balance.Net)
+		}
+		if owed := min(-nets[payload.FromUserID], nets[payload.ToUserID]); owed > 0 {
+			amount = fromCents(owed)
+		}
+		if amount == 0 {
+			app.badRequestResponse(w, r, fmt.Errorf("%s has no outstanding debt to %s", usernames[payload.FromUserID], usernames[payload.ToUserID]))
+			return
+		}
+	}
+
+	transaction := &store.GroupTransaction{
+		GroupID:         group.ID,
+		UserID:          payload.FromUserID,
+		CreatedBy:       usernames[payload.FromUserID],
+		Amount:          amount,
+		TransactionType: store.TransactionTypeSettlement,
+		Description:     fmt.Sprintf("%s paid %s", usernames[payload.FromUserID], usernames[payload.ToUserID]),
+		TransactionDate: time.Now().Format(time.RFC3339),
+		SplitType:       store.SplitExact,
+		Splits: []store.GroupTransactionSplit{
+			{UserID: payload.ToUserID, Username: usernames[payload.ToUserID], Amount: amount},
+		},
+	}
+
+	transactionData, err := app.store.GroupTransactions.Create(r.Context(), transaction)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, transactionData); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("group settlement recorded", "group", group.ID, "from", payload.FromUserID, "to", payload.ToUserID, "amount", amount)
+}
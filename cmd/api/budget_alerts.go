@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sumit8974/finance-tracker/internal/mail"
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+// parseBudgetAlertThresholds parses a comma separated list of percentages such
+// as "80,100". Invalid entries are skipped.
+func parseBudgetAlertThresholds(value string) []int {
+	var thresholds []int
+	for _, part := range strings.Split(value, ",") {
+		threshold, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || threshold <= 0 {
+			continue
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	slices.Sort(thresholds)
+	return slices.Compact(thresholds)
+}
+
+// checkBudgetAlerts mails the user when an expense pushed one of the budgets it
+// counts against past an alert threshold. It runs in the background so the
+// request is not held up by the mailer.
+func (app *application) checkBudgetAlerts(user *store.User, transaction *store.Transaction) {
+	if transaction.TransactionType != "expense" || len(app.config.budgetAlerts.thresholds) == 0 {
+		return
+	}
+	date, err := time.Parse(time.RFC3339, transaction.TransactionDate)
+	if err != nil {
+		app.logger.Errorw("invalid transaction date for budget alerts", "transaction", transaction.ID, "error", err)
+		return
+	}
+
+	app.background(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		statuses, err := app.store.Budgets.StatusForCategory(ctx, user.ID, transaction.CategoryID, date.Format(monthLayout))
+		if err != nil {
+			app.logger.Errorw("failed to load budgets for alerts", "user", user.ID, "error", err)
+			return
+		}
+		for _, status := range statuses {
+			app.sendBudgetAlert(ctx, user, status)
+		}
+	})
+}
+
+// sendBudgetAlert records every threshold the budget has reached and sends one
+// mail for the highest new one. If the mail cannot be sent the thresholds are
+// forgotten again, so the next expense retries the alert.
+func (app *application) sendBudgetAlert(ctx context.Context, user *store.User, status store.BudgetStatus) {
+	var reached []int
+	for _, threshold := range app.config.budgetAlerts.thresholds {
+		if status.PercentUsed < float64(threshold) {
+			break
+		}
+		recorded, err := app.store.Budgets.RecordAlert(ctx, status.ID, threshold)
+		if err != nil {
+			app.logger.Errorw("failed to record budget alert", "budget", status.ID, "threshold", threshold, "error", err)
+			continue
+		}
+		if recorded {
+			reached = append(reached, threshold)
+		}
+	}
+	if len(reached) == 0 {
+		return
+	}
+
+	threshold := reached[len(reached)-1]
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username     string
+		CategoryName string
+		Month        string
+		Threshold    int
+		Limit        float64
+		Spent        float64
+		Remaining    float64
+		Overspent    float64
+		PercentUsed  float64
+		BudgetsURL   string
+	}{
+		Username:     user.Username,
+		CategoryName: status.CategoryName,
+		Month:        status.Month,
+		Threshold:    threshold,
+		Limit:        status.Available,
+		Spent:        status.Spent,
+		Remaining:    status.Remaining,
+		Overspent:    math.Abs(status.Remaining),
+		PercentUsed:  status.PercentUsed,
+		BudgetsURL:   fmt.Sprintf("%s/budgets/%s", app.config.frontendURL, status.Month),
+	}
+	statusCode, err := app.mailer.Send(mail.BudgetAlertTemplate, user.Username, user.Email, vars, !isProdEnv)
+	if err != nil {
+		app.logger.Errorw("error sending budget alert email", "budget", status.ID, "threshold", threshold, "error", err)
+
+		// forget the alerts if the email fails so they fire again (SAGA pattern)
+		for _, threshold := range reached {
+			if err := app.store.Budgets.DeleteAlert(ctx, status.ID, threshold); err != nil {
+				app.logger.Errorw("error deleting budget alert", "budget", status.ID, "threshold", threshold, "error", err)
+			}
+		}
+		return
+	}
+	app.logger.Infow("budget alert sent", "budget", status.ID, "threshold", threshold, "status code", statusCode)
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+const monthLayout = "2006-01"
+
+type CreateBudgetRequest struct {
+	CategoryID int64   `json:"categoryId" validate:"required,gt=0"`
+	Month      string  `json:"month" validate:"required"` // YYYY-MM
+	Limit      float64 `json:"limit" validate:"required,gt=0"`
+	Envelope   bool    `json:"envelope"` // carry what is left into the next month
+}
+
+type UpdateBudgetRequest struct {
+	Limit float64 `json:"limit" validate:"required,gt=0"`
+}
+
+type BudgetMonthResponse struct {
+	Month          string               `json:"month"`
+	Budgets        []store.BudgetStatus `json:"budgets"`
+	TotalLimit     float64              `json:"totalLimit"`
+	TotalAvailable float64              `json:"totalAvailable"`
+	TotalSpent     float64              `json:"totalSpent"`
+	TotalRemaining float64              `json:"totalRemaining"`
+}
+
+// createBudgetHandler godoc
+//
+//	@Summary		Create a budget
+//	@Description	Set a monthly spending limit for an expense category. The limit also covers the category's sub-categories. An envelope budget records the limit as its first allocation and can be rolled over and topped up by transfers.
+//	@Tags			budgets
+//	@Accept			json
+//	@Produce		json
+//	@Param			budget	body		CreateBudgetRequest	true	"Budget data"
+//	@Success		201		{object}	store.Budget
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/budgets [post]
+func (app *application) createBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateBudgetRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if _, err := time.Parse(monthLayout, payload.Month); err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid month, expected YYYY-MM: %s", payload.Month))
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	category, err := app.store.Category.GetByID(ctx, payload.CategoryID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			app.badRequestResponse(w, r, errors.New("category not found"))
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !categoryVisibleTo(category, user.ID) {
+		app.badRequestResponse(w, r, errors.New("category not found"))
+		return
+	}
+	if category.Type != "expense" {
+		app.badRequestResponse(w, r, errors.New("budgets can only be set for expense categories"))
+		return
+	}
+
+	budget := &store.Budget{
+		UserID:       user.ID,
+		CategoryID:   category.ID,
+		CategoryName: category.Name,
+		Month:        payload.Month,
+		Limit:        payload.Limit,
+		Envelope:     payload.Envelope,
+	}
+	if err := app.store.Budgets.Create(ctx, budget); err != nil {
+		if err == store.ErrDuplicateBudget {
+			app.conflictResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, budget); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("budget created", "budget", budget.ID, "user", user.ID, "month", budget.Month)
+}
+
+// listBudgetsHandler godoc
+//
+//	@Summary		List budgets
+//	@Description	List the authenticated user's budgets, newest month first
+//	@Tags			budgets
+//	@Produce		json
+//	@Param			month	query		string	false	"Only budgets of this month (YYYY-MM)"
+//	@Success		200		{object}	[]store.Budget
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/budgets [get]
+func (app *application) listBudgetsHandler(w http.ResponseWriter, r *http.Request) {
+	month := r.URL.Query().Get("month")
+	if month != "" {
+		if _, err := time.Parse(monthLayout, month); err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid month, expected YYYY-MM: %s", month))
+			return
+		}
+	}
+
+	user := getUserFromContext(r)
+	budgets, err := app.store.Budgets.ListByUser(r.Context(), user.ID, month)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, budgets); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getBudgetMonthHandler godoc
+//
+//	@Summary		Get a month's budgets
+//	@Description	Get every budget of a month with the amount available, the amount spent, the amount remaining and the percentage used
+//	@Tags			budgets
+//	@Produce		json
+//	@Param			month	path		string	true	"Month (YYYY-MM)"
+//	@Success		200		{object}	BudgetMonthResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/budgets/{month} [get]
+func (app *application) getBudgetMonthHandler(w http.ResponseWriter, r *http.Request) {
+	month := chi.URLParam(r, "month")
+	if _, err := time.Parse(monthLayout, month); err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid month, expected YYYY-MM: %s", month))
+		return
+	}
+
+	user := getUserFromContext(r)
+	statuses, err := app.store.Budgets.StatusByMonth(r.Context(), user.ID, month)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := BudgetMonthResponse{Month: month, Budgets: statuses}
+	var limit, available, spent int64
+	for _, status := range statuses {
+		limit += toCents(status.Limit)
+		available += toCents(status.Available)
+		spent += toCents(status.Spent)
+	}
+	response.TotalLimit = fromCents(limit)
+	response.TotalAvailable = fromCents(available)
+	response.TotalSpent = fromCents(spent)
+	response.TotalRemaining = fromCents(available - spent)
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getBudgetHandler godoc
+//
+//	@Summary		Get a budget
+//	@Description	Get a budget by ID
+//	@Tags			budgets
+//	@Produce		json
+//	@Param			id	path		int	true	"Budget ID"
+//	@Success		200	{object}	store.Budget
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/budgets/{id} [get]
+func (app *application) getBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, getBudgetFromContext(r)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// updateBudgetHandler godoc
+//
+//	@Summary		Update a budget
+//	@Description	Change the limit of a budget. For an envelope the difference is added to its ledger.
+//	@Tags			budgets
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Budget ID"
+//	@Param			budget	body		UpdateBudgetRequest	true	"Budget data"
+//	@Success		200		{object}	store.Budget
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/budgets/{id} [patch]
+func (app *application) updateBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateBudgetRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	budget := getBudgetFromContext(r)
+	budget.Limit = payload.Limit
+	if err := app.store.Budgets.Update(r.Context(), budget); err != nil {
+		if err == store.ErrNotFound {
+			app.notFoundResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, budget); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("budget updated", "budget", budget.ID, "limit", budget.Limit)
+}
+
+// deleteBudgetHandler godoc
+//
+//	@Summary		Delete a budget
+//	@Description	Delete a budget by ID. The ledgers of envelopes it exchanged money with keep their entries.
+//	@Tags			budgets
+//	@Produce		json
+//	@Param			id	path		int	true	"Budget ID"
+//	@Success		204	{object}	nil
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/budgets/{id} [delete]
+func (app *application) deleteBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	budget := getBudgetFromContext(r)
+	if err := app.store.Budgets.Delete(r.Context(), budget.ID); err != nil {
+		if err == store.ErrNotFound {
+			app.notFoundResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("budget deleted", "budget", budget.ID)
+}
+
+func (app *application) budgetContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		budgetID := chi.URLParam(r, "id")
+		budgetIDInt, err := strconv.ParseInt(budgetID, 10, 64)
+		if err != nil || budgetIDInt <= 0 {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid budget ID: %s", budgetID))
+			return
+		}
+
+		ctx := r.Context()
+		budget, err := app.store.Budgets.GetByID(ctx, budgetIDInt)
+		if err != nil {
+			if err == store.ErrNotFound {
+				app.notFoundResponse(w, r, err)
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+		ctx = context.WithValue(r.Context(), budgetCtx, budget)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getBudgetFromContext(r *http.Request) *store.Budget {
+	budget, _ := r.Context().Value(budgetCtx).(*store.Budget)
+	return budget
+}
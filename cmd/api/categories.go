@@ -1,11 +1,20 @@
 package main
 
-import "net/http"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
 
 // listCategories godoc
 //
 //	@Summary		List all categories
-//	@Description	List all categories
+//	@Description	List the system categories and the authenticated user's own categories as a tree of sub-categories
 //	@Tags			categories
 //	@Accept			json
 //	@Produce		json
@@ -15,13 +24,349 @@ import "net/http"
 // @Security		ApiKeyAuth
 func (app *application) listCategoriesHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	categories, err := app.store.Category.ListCategories(ctx)
+	user := getUserFromContext(r)
+	categories, err := app.store.Category.ListCategories(ctx, user.ID)
 	if err != nil {
 		app.logger.Errorw("failed to get categories", "error", err)
 		app.internalServerError(w, r, err)
 		return
 	}
-	if err := app.jsonResponse(w, http.StatusOK, categories); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, store.BuildCategoryTree(categories)); err != nil {
 		app.logger.Errorw("failed to write response", "error", err)
 	}
-}
\ No newline at end of file
+}
+
+// categoryTotalsHandler godoc
+//
+//	@Summary		Category totals
+//	@Description	Sum the authenticated user's transactions per category. Totals of sub-categories are rolled up into their parents.
+//	@Tags			categories
+//	@Produce		json
+//	@Param			startDate		query		string	false	"Start transaction date (YYYY-MM-DD, inclusive)"
+//	@Param			endDate			query		string	false	"End transaction date (YYYY-MM-DD, inclusive)"
+//	@Param			transactionType	query		string	false	"Transaction type (income/expense)"
+//	@Success		200				{object}	[]store.CategoryTotal
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/categories/totals [get]
+func (app *application) categoryTotalsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseTransactionFilter(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	totals, err := app.store.Category.Totals(r.Context(), user.ID, filter)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, totals); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type CreateCategoryRequest struct {
+	Name     string `json:"name" validate:"required,max=50"`
+	Type     string `json:"type" validate:"required,oneof=income expense"`
+	ParentID *int64 `json:"parentId" validate:"omitempty,gt=0"`
+}
+
+// createCategoryHandler godoc
+//
+//	@Summary		Create a category
+//	@Description	Create a custom category owned by the authenticated user, optionally nested under a parent category of the same type
+//	@Tags			categories
+//	@Accept			json
+//	@Produce		json
+//	@Param			category	body		CreateCategoryRequest	true	"Category data"
+//	@Success		201			{object}	store.Category
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/categories [post]
+func (app *application) createCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateCategoryRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	// a custom category must not shadow a system category with the same name
+	if _, err := app.store.Category.GetByName(ctx, user.ID, payload.Name); err == nil {
+		app.conflictResponse(w, r, store.ErrDuplicateCategory)
+		return
+	} else if err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if payload.ParentID != nil {
+		if err := app.validateParentCategory(r, *payload.ParentID, payload.Type, 0); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	category, err := app.store.Category.Create(ctx, &store.Category{
+		Name:     payload.Name,
+		Type:     payload.Type,
+		OwnerID:  &user.ID,
+		ParentID: payload.ParentID,
+	})
+	if err != nil {
+		if err == store.ErrDuplicateCategory {
+			app.conflictResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, category); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("category created", "category", category.ID, "user", user.ID)
+}
+
+type UpdateCategoryRequest struct {
+	Name string `json:"name" validate:"required,max=50"`
+	// ParentID moves the category under another parent, 0 moves it to the top level
+	// and omitting it keeps the current parent.
+	ParentID *int64 `json:"parentId" validate:"omitempty,gte=0"`
+}
+
+// updateCategoryHandler godoc
+//
+//	@Summary		Rename or move a category
+//	@Description	Rename a custom category owned by the authenticated user or move it under another parent
+//	@Tags			categories
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int						true	"Category ID"
+//	@Param			category	body		UpdateCategoryRequest	true	"Category data"
+//	@Success		200			{object}	store.Category
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/categories/{id} [patch]
+func (app *application) updateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateCategoryRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	category := getCategoryFromContext(r)
+
+	existing, err := app.store.Category.GetByName(ctx, user.ID, payload.Name)
+	if err == nil && existing.ID != category.ID {
+		app.conflictResponse(w, r, store.ErrDuplicateCategory)
+		return
+	} else if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if payload.ParentID != nil {
+		if *payload.ParentID == 0 {
+			category.ParentID = nil
+		} else {
+			if err := app.validateParentCategory(r, *payload.ParentID, category.Type, category.ID); err != nil {
+				app.badRequestResponse(w, r, err)
+				return
+			}
+			category.ParentID = payload.ParentID
+		}
+	}
+
+	category.Name = payload.Name
+	if err := app.store.Category.Update(ctx, category); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrDuplicateCategory:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, category); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("category renamed", "category", category.ID, "name", category.Name)
+}
+
+// deleteCategoryHandler godoc
+//
+//	@Summary		Delete a category
+//	@Description	Delete a custom category owned by the authenticated user. A category still used by transactions, budgets or goals can only be deleted with a replacement category, which takes them over. Budgets that collide with one of the replacement's are merged into it.
+//	@Tags			categories
+//	@Produce		json
+//	@Param			id				path		int	true	"Category ID"
+//	@Param			replacementId	query		int	false	"Category that takes over the deleted category's transactions, budgets and goals"
+//	@Success		204				{object}	nil
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		409				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/categories/{id} [delete]
+func (app *application) deleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	category := getCategoryFromContext(r)
+
+	var replacementID int64
+	if replacement := r.URL.Query().Get("replacementId"); replacement != "" {
+		id, err := strconv.ParseInt(replacement, 10, 64)
+		if err != nil || id <= 0 {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid replacement category ID: %s", replacement))
+			return
+		}
+		if id == category.ID {
+			app.badRequestResponse(w, r, errors.New("a category cannot replace itself"))
+			return
+		}
+
+		replacementCategory, err := app.store.Category.GetByID(ctx, id)
+		if err != nil {
+			if err == store.ErrNotFound {
+				app.badRequestResponse(w, r, errors.New("replacement category not found"))
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !categoryVisibleTo(replacementCategory, user.ID) {
+			app.badRequestResponse(w, r, errors.New("replacement category not found"))
+			return
+		}
+		if replacementCategory.Type != category.Type {
+			app.badRequestResponse(w, r, fmt.Errorf("replacement category must be an %s category", category.Type))
+			return
+		}
+		replacementID = replacementCategory.ID
+	}
+
+	if err := app.store.Category.Delete(ctx, category.ID, replacementID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrCategoryInUse, store.ErrCategoryHasChildren:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("category deleted", "category", category.ID, "replacement", replacementID)
+}
+
+// validateParentCategory checks that parentID can be the parent of a category of
+// the given type. categoryID is the category being moved, 0 for a new one; it
+// must not end up below itself.
+func (app *application) validateParentCategory(r *http.Request, parentID int64, categoryType string, categoryID int64) error {
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	parent, err := app.store.Category.GetByID(ctx, parentID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return errors.New("parent category not found")
+		}
+		return err
+	}
+	if !categoryVisibleTo(parent, user.ID) {
+		return errors.New("parent category not found")
+	}
+	if parent.Type != categoryType {
+		return fmt.Errorf("parent category must be an %s category", categoryType)
+	}
+
+	for ancestor := parent; ; {
+		if ancestor.ID == categoryID {
+			return errors.New("a category cannot be moved under itself or one of its sub-categories")
+		}
+		if ancestor.ParentID == nil {
+			return nil
+		}
+		if ancestor, err = app.store.Category.GetByID(ctx, *ancestor.ParentID); err != nil {
+			return err
+		}
+	}
+}
+
+func categoryVisibleTo(category *store.Category, userID int64) bool {
+	return category.OwnerID == nil || *category.OwnerID == userID
+}
+
+func (app *application) categoryContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		categoryID := chi.URLParam(r, "id")
+		categoryIDInt, err := strconv.ParseInt(categoryID, 10, 64)
+		if err != nil || categoryIDInt <= 0 {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid category ID: %s", categoryID))
+			return
+		}
+
+		ctx := r.Context()
+		category, err := app.store.Category.GetByID(ctx, categoryIDInt)
+		if err != nil {
+			if err == store.ErrNotFound {
+				app.notFoundResponse(w, r, err)
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		// other users' categories are reported as missing
+		if !categoryVisibleTo(category, getUserFromContext(r).ID) {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+
+		ctx = context.WithValue(r.Context(), categoryCtx, category)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getCategoryFromContext(r *http.Request) *store.Category {
+	category, _ := r.Context().Value(categoryCtx).(*store.Category)
+	return category
+}
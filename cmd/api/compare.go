@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+// comparisonMovers is how many categories are listed as having grown or shrunk the most.
+const comparisonMovers = 5
+
+type ComparisonPeriod struct {
+	StartDate string  `json:"startDate"`
+	EndDate   string  `json:"endDate"`
+	Income    float64 `json:"income"`
+	Expenses  float64 `json:"expenses"`
+}
+
+// CategoryComparison compares the total of a category, including its
+// sub-categories, in both periods. PercentChange is nil when the category had
+// nothing in the previous period.
+type CategoryComparison struct {
+	CategoryID    int64    `json:"categoryId"`
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	ParentID      *int64   `json:"parentId"`
+	Current       float64  `json:"current"`
+	Previous      float64  `json:"previous"`
+	Change        float64  `json:"change"`
+	PercentChange *float64 `json:"percentChange"`
+}
+
+type ComparisonResponse struct {
+	Current    ComparisonPeriod     `json:"current"`
+	Previous   ComparisonPeriod     `json:"previous"`
+	Categories []CategoryComparison `json:"categories"`
+	Grew       []CategoryComparison `json:"grew"`
+	Shrank     []CategoryComparison `json:"shrank"`
+}
+
+// comparePeriods compares the rolled up category totals of two periods.
+// Categories are ordered by the size of their change, largest first, and those
+// without transactions in either period are left out.
+func comparePeriods(current, previous []*store.CategoryTotal) []CategoryComparison {
+	previousTotals := make(map[int64]float64)
+	var collect func(totals []*store.CategoryTotal)
+	collect = func(totals []*store.CategoryTotal) {
+		for _, total := range totals {
+			previousTotals[total.CategoryID] = total.Total
+			collect(total.Children)
+		}
+	}
+	collect(previous)
+
+	comparisons := []CategoryComparison{}
+	var walk func(totals []*store.CategoryTotal)
+	walk = func(totals []*store.CategoryTotal) {
+		for _, total := range totals {
+			walk(total.Children)
+			previousTotal := previousTotals[total.CategoryID]
+			if toCents(total.Total) == 0 && toCents(previousTotal) == 0 {
+				continue
+			}
+			change := toCents(total.Total) - toCents(previousTotal)
+			comparison := CategoryComparison{
+				CategoryID: total.CategoryID,
+				Name:       total.Name,
+				Type:       total.Type,
+				ParentID:   total.ParentID,
+				Current:    roundCents(total.Total),
+				Previous:   roundCents(previousTotal),
+				Change:     fromCents(change),
+			}
+			if toCents(previousTotal) != 0 {
+				percent := math.Round(float64(change)*10000/float64(toCents(previousTotal))) / 100
+				comparison.PercentChange = &percent
+			}
+			comparisons = append(comparisons, comparison)
+		}
+	}
+	walk(current)
+
+	sort.SliceStable(comparisons, func(i, j int) bool {
+		return math.Abs(comparisons[i].Change) > math.Abs(comparisons[j].Change)
+	})
+	return comparisons
+}
+
+// comparisonPeriod sums the income and expenses of a period's category totals.
+func comparisonPeriod(startDate, endDate string, totals []*store.CategoryTotal) ComparisonPeriod {
+	period := ComparisonPeriod{StartDate: startDate, EndDate: endDate}
+	var income, expenses int64
+	for _, total := range totals {
+		if total.Type == "income" {
+			income += toCents(total.Total)
+		} else {
+			expenses += toCents(total.Total)
+		}
+	}
+	period.Income = fromCents(income)
+	period.Expenses = fromCents(expenses)
+	return period
+}
+
+// precedingPeriod returns the period of equal length that ends the day before
+// start. A period of whole calendar months is followed by as many whole months,
+// so March is compared against February rather than the last 31 days.
+func precedingPeriod(start, end time.Time) (time.Time, time.Time) {
+	next := end.AddDate(0, 0, 1)
+	if start.Day() == 1 && next.Day() == 1 {
+		months := (next.Year()-start.Year())*12 + int(next.Month()-start.Month())
+		return start.AddDate(0, -months, 0), start.AddDate(0, 0, -1)
+	}
+	days := int(next.Sub(start).Hours() / 24)
+	return start.AddDate(0, 0, -days), start.AddDate(0, 0, -1)
+}
+
+// parseComparisonPeriods reads the current period and the period it is
+// compared against. Without previousStart and previousEnd the previous period
+// is the one right before the current one (see precedingPeriod), or with
+// against=year the same dates one year earlier.
+func parseComparisonPeriods(r *http.Request) (currentStart, currentEnd, previousStart, previousEnd time.Time, err error) {
+	queryParams := r.URL.Query()
+	parse := func(name string) (time.Time, error) {
+		value := queryParams.Get(name)
+		if value == "" {
+			return time.Time{}, nil
+		}
+		date, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return date, fmt.Errorf("invalid %s, expected YYYY-MM-DD: %s", name, value)
+		}
+		return date, nil
+	}
+
+	if currentStart, err = parse("currentStart"); err != nil {
+		return
+	}
+	if currentEnd, err = parse("currentEnd"); err != nil {
+		return
+	}
+	if currentStart.IsZero() || currentEnd.IsZero() {
+		err = errors.New("currentStart and currentEnd are required")
+		return
+	}
+	if currentEnd.Before(currentStart) {
+		err = errors.New("currentEnd must not be before currentStart")
+		return
+	}
+
+	if previousStart, err = parse("previousStart"); err != nil {
+		return
+	}
+	if previousEnd, err = parse("previousEnd"); err != nil {
+		return
+	}
+	switch {
+	case !previousStart.IsZero() && !previousEnd.IsZero():
+		if previousEnd.Before(previousStart) {
+			err = errors.New("previousEnd must not be before previousStart")
+		}
+	case !previousStart.IsZero() || !previousEnd.IsZero():
+		err = errors.New("previousStart and previousEnd must be given together")
+	case queryParams.Get("against") == "year":
+		previousStart, previousEnd = currentStart.AddDate(-1, 0, 0), currentEnd.AddDate(-1, 0, 0)
+	case queryParams.Get("against") == "" || queryParams.Get("against") == "previous":
+		previousStart, previousEnd = precedingPeriod(currentStart, currentEnd)
+	default:
+		err = fmt.Errorf("invalid against, expected previous or year: %s", queryParams.Get("against"))
+	}
+	return
+}
+
+// comparePeriodsHandler godoc
+//
+//	@Summary		Compare two periods
+//	@Description	Compare the authenticated user's transactions per category between two periods, e.g. this month against last month or a quarter against the same quarter last year. The transaction list filters narrow both periods.
+//	@Tags			analytics
+//	@Produce		json
+//	@Param			currentStart	query		string	true	"Start of the current period (YYYY-MM-DD, inclusive)"
+//	@Param			currentEnd		query		string	true	"End of the current period (YYYY-MM-DD, inclusive)"
+//	@Param			previousStart	query		string	false	"Start of the period to compare against (YYYY-MM-DD, inclusive)"
+//	@Param			previousEnd		query		string	false	"End of the period to compare against (YYYY-MM-DD, inclusive)"
+//	@Param			against			query		string	false	"Without previousStart/previousEnd: previous (default) for the period right before, year for the same dates a year earlier"
+//	@Param			transactionType	query		string	false	"Transaction type (income/expense)"
+//	@Param			categoryIds		query		[]int	false	"Category IDs, repeated or comma separated"
+//	@Param			minAmount		query		number	false	"Minimum amount"
+//	@Param			maxAmount		query		number	false	"Maximum amount"
+//	@Param			search			query		string	false	"Case insensitive description substring"
+//	@Success		200				{object}	ComparisonResponse
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/analytics/compare [get]
+func (app *application) comparePeriodsHandler(w http.ResponseWriter, r *http.Request) {
+	currentStart, currentEnd, previousStart, previousEnd, err := parseComparisonPeriods(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	filter, err := parseTransactionFilter(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(filter); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	totals := func(start, end time.Time) ([]*store.CategoryTotal, error) {
+		filter.StartDate = start.Format(time.DateOnly)
+		filter.EndDate = end.Format(time.DateOnly)
+		return app.store.Category.Totals(ctx, user.ID, filter)
+	}
+	current, err := totals(currentStart, currentEnd)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	previous, err := totals(previousStart, previousEnd)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := ComparisonResponse{
+		Current:    comparisonPeriod(currentStart.Format(time.DateOnly), currentEnd.Format(time.DateOnly), current),
+		Previous:   comparisonPeriod(previousStart.Format(time.DateOnly), previousEnd.Format(time.DateOnly), previous),
+		Categories: comparePeriods(current, previous),
+		Grew:       []CategoryComparison{},
+		Shrank:     []CategoryComparison{},
+	}
+	for _, comparison := range response.Categories {
+		if comparison.Change > 0 && len(response.Grew) < comparisonMovers {
+			response.Grew = append(response.Grew, comparison)
+		}
+		if comparison.Change < 0 && len(response.Shrank) < comparisonMovers {
+			response.Shrank = append(response.Shrank, comparison)
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+type TransferBudgetRequest struct {
+	FromBudgetID int64   `json:"fromBudgetId" validate:"required,gt=0"`
+	ToBudgetID   int64   `json:"toBudgetId" validate:"required,gt=0,nefield=FromBudgetID"`
+	Amount       float64 `json:"amount" validate:"required,gt=0"`
+	Note         string  `json:"note" validate:"max=255"`
+}
+
+type RolloverResponse struct {
+	Month       string                   `json:"month"`
+	Allocations []store.BudgetAllocation `json:"allocations"`
+}
+
+// rolloverBudgetsHandler godoc
+//
+//	@Summary		Roll envelopes over
+//	@Description	Carry what is left (or overspent) in the previous month's envelope budgets into this month's envelopes, creating them if needed. Repeating the rollover only records the difference.
+//	@Tags			budgets
+//	@Produce		json
+//	@Param			month	path		string	true	"Month to roll into (YYYY-MM)"
+//	@Success		200		{object}	RolloverResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/budgets/{month}/rollover [post]
+func (app *application) rolloverBudgetsHandler(w http.ResponseWriter, r *http.Request) {
+	month := chi.URLParam(r, "month")
+	if _, err := time.Parse(monthLayout, month); err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid month, expected YYYY-MM: %s", month))
+		return
+	}
+
+	user := getUserFromContext(r)
+	allocations, err := app.store.Budgets.RollOver(r.Context(), user.ID, month)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := RolloverResponse{Month: month, Allocations: allocations}
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("budgets rolled over", "user", user.ID, "month", month, "entries", len(allocations))
+}
+
+// transferBudgetHandler godoc
+//
+//	@Summary		Move money between envelopes
+//	@Description	Move money from one envelope budget to another of the same month. The move is recorded in both ledgers.
+//	@Tags			budgets
+//	@Accept			json
+//	@Produce		json
+//	@Param			transfer	body		TransferBudgetRequest	true	"Transfer data"
+//	@Success		201			{object}	[]store.BudgetAllocation
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/budgets/transfers [post]
+func (app *application) transferBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TransferBudgetRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	var budgets [2]*store.Budget
+	for i, id := range []int64{payload.FromBudgetID, payload.ToBudgetID} {
+		budget, err := app.store.Budgets.GetByID(ctx, id)
+		if err != nil {
+			if err == store.ErrNotFound {
+				app.notFoundResponse(w, r, err)
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+		if budget.UserID != user.ID {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+		if !budget.Envelope {
+			app.badRequestResponse(w, r, fmt.Errorf("budget %d is not an envelope", budget.ID))
+			return
+		}
+		budgets[i] = budget
+	}
+	from, to := budgets[0], budgets[1]
+	if from.Month != to.Month {
+		app.badRequestResponse(w, r, errors.New("money can only be moved between envelopes of the same month"))
+		return
+	}
+
+	allocations, err := app.store.Budgets.Transfer(ctx, from, to, payload.Amount, payload.Note)
+	if err != nil {
+		switch err {
+		case store.ErrInsufficientFunds:
+			app.conflictResponse(w, r, err)
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, allocations); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("envelope transfer", "user", user.ID, "from", from.ID, "to", to.ID, "amount", payload.Amount)
+}
+
+// listBudgetAllocationsHandler godoc
+//
+//	@Summary		Get an envelope's ledger
+//	@Description	List the allocations, rollovers and transfers that make up what an envelope budget holds, oldest first
+//	@Tags			budgets
+//	@Produce		json
+//	@Param			id	path		int	true	"Budget ID"
+//	@Success		200	{object}	[]store.BudgetAllocation
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/budgets/{id}/ledger [get]
+func (app *application) listBudgetAllocationsHandler(w http.ResponseWriter, r *http.Request) {
+	budget := getBudgetFromContext(r)
+	allocations, err := app.store.Budgets.ListAllocations(r.Context(), budget.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, allocations); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -11,7 +11,7 @@ func (app *application) internalServerError(w http.ResponseWriter, r *http.Reque
 }
 
 func (app *application) forbiddenResponse(w http.ResponseWriter, r *http.Request) {
-	app.logger.Warnw("forbidden", "method", r.Method, "path", r.URL.Path, "error")
+	app.logger.Warnw("forbidden", "method", r.Method, "path", r.URL.Path)
 
 	writeJSONError(w, http.StatusForbidden, "forbidden")
 }
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sumit8974/finance-tracker/internal/store"
+	"github.com/sumit8974/finance-tracker/internal/xlsx"
+)
+
+// exportFlushRows is how many rows are buffered before they are sent to the client.
+const exportFlushRows = 500
+
+// exportWriteTimeout is how long an export may take to send the next batch of
+// rows. The server's WriteTimeout would otherwise cut off large exports.
+const exportWriteTimeout = 30 * time.Second
+
+var exportContentTypes = map[string]string{
+	"csv":  "text/csv; charset=utf-8",
+	"json": "application/json",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+var exportHeader = []string{"id", "date", "type", "category", "amount", "description"}
+
+// transactionExporter writes transactions in one export format.
+type transactionExporter interface {
+	Write(*store.Transaction) error
+	Flush() error
+	Close() error
+}
+
+// exportTransactionsHandler godoc
+//
+//	@Summary		Export transactions
+//	@Description	Download the authenticated user's transactions matching the list filters as CSV, JSON or XLSX, oldest first
+//	@Tags			transactions
+//	@Produce		text/csv,json,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Param			format			query	string	false	"csv (default), json or xlsx"
+//	@Param			startDate		query	string	false	"Start transaction date (YYYY-MM-DD, inclusive)"
+//	@Param			endDate			query	string	false	"End transaction date (YYYY-MM-DD, inclusive)"
+//	@Param			transactionType	query	string	false	"Transaction type (income/expense)"
+//	@Param			categoryIds		query	[]int	false	"Category IDs, repeated or comma separated"
+//	@Param			minAmount		query	number	false	"Minimum amount"
+//	@Param			maxAmount		query	number	false	"Maximum amount"
+//	@Param			search			query	string	false	"Case insensitive description substring"
+//	@Success		200	{file}		file
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/transactions/export [get]
+func (app *application) exportTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid export format: %s", format))
+		return
+	}
+
+	filter, err := parseTransactionFilter(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(filter); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// nothing reaches the client until the first buffer is full, so an export
+	// that fails early can still be answered with an error
+	out := &startedWriter{w: w}
+	buf := bufio.NewWriterSize(out, 64<<10)
+
+	var exporter transactionExporter
+	switch format {
+	case "json":
+		exporter = newJSONExporter(buf)
+	case "xlsx":
+		exporter, err = newXLSXExporter(buf)
+	default:
+		exporter, err = newCSVExporter(buf)
+	}
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	filename := fmt.Sprintf("transactions-%s.%s", time.Now().Format("20060102"), format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	controller := http.NewResponseController(w)
+	extendDeadline := func() error {
+		err := controller.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+		if errors.Is(err, http.ErrNotSupported) {
+			return nil
+		}
+		return err
+	}
+	if err := extendDeadline(); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	rows := 0
+	err = app.store.Transactions.Export(r.Context(), user.ID, filter, func(transaction *store.Transaction) error {
+		if err := exporter.Write(transaction); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushRows == 0 {
+			if err := exporter.Flush(); err != nil {
+				return err
+			}
+			if err := buf.Flush(); err != nil {
+				return err
+			}
+			if err := controller.Flush(); err != nil {
+				return err
+			}
+			return extendDeadline()
+		}
+		return nil
+	})
+	if err == nil {
+		if err = exporter.Close(); err == nil {
+			err = buf.Flush()
+		}
+	}
+	if err != nil {
+		if !out.started {
+			w.Header().Del("Content-Disposition")
+			app.internalServerError(w, r, err)
+			return
+		}
+		// the response is already on its way, all we can do is cut it short
+		app.logger.Errorw("transaction export failed", "user", user.ID, "format", format, "rows", rows, "error", err)
+		return
+	}
+	app.logger.Infow("transactions exported", "user", user.ID, "format", format, "rows", rows)
+}
+
+// startedWriter records whether anything has been written to the response.
+type startedWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (s *startedWriter) Write(p []byte) (int, error) {
+	s.started = true
+	return s.w.Write(p)
+}
+
+// exportDate returns the ISO date (YYYY-MM-DD) of a transaction date.
+func exportDate(value string) string {
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	return date.Format(time.DateOnly)
+}
+
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func newCSVExporter(w io.Writer) (*csvExporter, error) {
+	e := &csvExporter{w: csv.NewWriter(w)}
+	return e, e.w.Write(exportHeader)
+}
+
+func (e *csvExporter) Write(t *store.Transaction) error {
+	return e.w.Write([]string{
+		strconv.FormatInt(t.ID, 10),
+		exportDate(t.TransactionDate),
+		t.TransactionType,
+		csvText(t.CategoryName),
+		strconv.FormatFloat(t.Amount, 'f', 2, 64),
+		csvText(t.Description),
+	})
+}
+
+// csvText keeps spreadsheets from running user supplied text as a formula by
+// prefixing cells that start like one with a quote.
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func (e *csvExporter) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExporter) Close() error {
+	return e.Flush()
+}
+
+// jsonExporter writes a JSON array one transaction at a time.
+type jsonExporter struct {
+	w    io.Writer
+	rows int
+}
+
+func newJSONExporter(w io.Writer) *jsonExporter {
+	return &jsonExporter{w: w}
+}
+
+func (e *jsonExporter) Write(t *store.Transaction) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	separator := ","
+	if e.rows == 0 {
+		separator = "["
+	}
+	e.rows++
+	if _, err := io.WriteString(e.w, separator); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonExporter) Flush() error {
+	return nil
+}
+
+func (e *jsonExporter) Close() error {
+	end := "]\n"
+	if e.rows == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+type xlsxExporter struct {
+	w *xlsx.Writer
+}
+
+func newXLSXExporter(w io.Writer) (*xlsxExporter, error) {
+	writer, err := xlsx.NewWriter(w, "Transactions")
+	if err != nil {
+		return nil, err
+	}
+	header := make([]any, len(exportHeader))
+	for i, name := range exportHeader {
+		header[i] = name
+	}
+	return &xlsxExporter{w: writer}, writer.WriteRow(header...)
+}
+
+func (e *xlsxExporter) Write(t *store.Transaction) error {
+	return e.w.WriteRow(t.ID, exportDate(t.TransactionDate), t.TransactionType, t.CategoryName, t.Amount, t.Description)
+}
+
+func (e *xlsxExporter) Flush() error {
+	return e.w.Flush()
+}
+
+func (e *xlsxExporter) Close() error {
+	return e.w.Close()
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+const (
+	defaultForecastMonths = 3
+	maxForecastMonths     = 24
+	// forecastHistoryMonths is how many full months of history the category
+	// averages and their variance are taken from.
+	forecastHistoryMonths = 12
+	// forecastConfidenceZ is the z-score of the 95% confidence band.
+	forecastConfidenceZ = 1.96
+)
+
+type ForecastMonth struct {
+	Month             string  `json:"month"` // YYYY-MM
+	Income            float64 `json:"income"`
+	Expenses          float64 `json:"expenses"`
+	RecurringIncome   float64 `json:"recurringIncome"`
+	RecurringExpenses float64 `json:"recurringExpenses"`
+	Net               float64 `json:"net"`
+	EndingBalance     float64 `json:"endingBalance"`
+	EndingBalanceLow  float64 `json:"endingBalanceLow"`
+	EndingBalanceHigh float64 `json:"endingBalanceHigh"`
+}
+
+// ForecastResponse projects the balance month by month. The first month is the
+// current one and only covers the days still ahead.
+type ForecastResponse struct {
+	StartingBalance float64         `json:"startingBalance"`
+	ConfidenceLevel float64         `json:"confidenceLevel"`
+	Months          []ForecastMonth `json:"months"`
+}
+
+// forecastCashFlow projects income and expenses for the given number of months
+// from now. Recurring transactions contribute their scheduled occurrences and
+// every other category its historical monthly average. The variance of those
+// averages widens the band around the ending balance as months accumulate.
+func forecastCashFlow(now time.Time, months int, balance float64, averages []store.CategoryAverage, recurring []store.RecurringTransaction) ForecastResponse {
+	response := ForecastResponse{
+		StartingBalance: roundCents(balance),
+		ConfidenceLevel: 0.95,
+		Months:          make([]ForecastMonth, 0, months),
+	}
+
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	var cumulativeVariance float64
+	for i := 0; i < months; i++ {
+		start := monthStart.AddDate(0, i, 0)
+		end := start.AddDate(0, 1, 0)
+
+		// the current month is only forecast for the part that lies ahead
+		share := 1.0
+		if i == 0 {
+			share = end.Sub(now).Hours() / end.Sub(start).Hours()
+		}
+
+		var income, expenses, recurringIncome, recurringExpenses, variance float64
+		for _, average := range averages {
+			if average.TransactionType == "income" {
+				income += average.Average * share
+			} else {
+				expenses += average.Average * share
+			}
+			variance += average.Variance * share
+		}
+
+		for _, rt := range recurring {
+			for _, date := range rt.PendingOccurrences(end) {
+				// occurrences that are due but not posted yet land in the current month
+				if i > 0 && date.Before(start) {
+					continue
+				}
+				if rt.TransactionType == "income" {
+					recurringIncome += rt.Amount
+				} else {
+					recurringExpenses += rt.Amount
+				}
+			}
+		}
+		income += recurringIncome
+		expenses += recurringExpenses
+
+		net := income - expenses
+		balance += net
+		cumulativeVariance += variance
+		band := forecastConfidenceZ * math.Sqrt(cumulativeVariance)
+
+		response.Months = append(response.Months, ForecastMonth{
+			Month:             start.Format(monthLayout),
+			Income:            roundCents(income),
+			Expenses:          roundCents(expenses),
+			RecurringIncome:   roundCents(recurringIncome),
+			RecurringExpenses: roundCents(recurringExpenses),
+			Net:               roundCents(net),
+			EndingBalance:     roundCents(balance),
+			EndingBalanceLow:  roundCents(balance - band),
+			EndingBalanceHigh: roundCents(balance + band),
+		})
+	}
+	return response
+}
+
+func roundCents(amount float64) float64 {
+	return fromCents(toCents(amount))
+}
+
+// analyticsForecastHandler godoc
+//
+//	@Summary		Forecast cash flow
+//	@Description	Project income, expenses and the ending balance of the next months from the recurring transactions and the average monthly amounts of the last 12 months per category, with a 95% confidence band
+//	@Tags			analytics
+//	@Produce		json
+//	@Param			months	query		int	false	"Number of months including the current one (default 3, at most 24)"
+//	@Success		200		{object}	ForecastResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/analytics/forecast [get]
+func (app *application) analyticsForecastHandler(w http.ResponseWriter, r *http.Request) {
+	months := defaultForecastMonths
+	if value := r.URL.Query().Get("months"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxForecastMonths {
+			app.badRequestResponse(w, r, fmt.Errorf("months must be between 1 and %d", maxForecastMonths))
+			return
+		}
+		months = n
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	now := time.Now().UTC()
+	historyEnd := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	balance, err := app.store.Analytics.Balance(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	averages, err := app.store.Analytics.CategoryAverages(ctx, user.ID, historyEnd.AddDate(0, -forecastHistoryMonths, 0), historyEnd)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	recurring, err := app.store.RecurringTransactions.ListByUser(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, forecastCashFlow(now, months, balance, averages, recurring)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,517 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+// daysPerMonth is the average length of a month, used to turn daily rates into monthly ones.
+const daysPerMonth = 365.25 / 12
+
+type CreateGoalRequest struct {
+	Name         string  `json:"name" validate:"required,max=100"`
+	TargetAmount float64 `json:"targetAmount" validate:"required,gt=0"`
+	TargetDate   string  `json:"targetDate" validate:"required"` // YYYY-MM-DD
+	CategoryID   *int64  `json:"categoryId" validate:"omitempty,gt=0"`
+}
+
+type UpdateGoalRequest struct {
+	Name         *string  `json:"name" validate:"omitempty,max=100"`
+	TargetAmount *float64 `json:"targetAmount" validate:"omitempty,gt=0"`
+	TargetDate   *string  `json:"targetDate"` // YYYY-MM-DD
+	// CategoryID links the goal to a category; 0 removes the link.
+	CategoryID *int64 `json:"categoryId" validate:"omitempty,gte=0"`
+}
+
+// CreateGoalContributionRequest is either a deposit (amount, optional date) or
+// the id of a transaction to tag with the goal.
+type CreateGoalContributionRequest struct {
+	TransactionID *int64  `json:"transactionId" validate:"omitempty,gt=0"`
+	Amount        float64 `json:"amount" validate:"required_without=TransactionID,omitempty,gt=0"`
+	Date          string  `json:"date"` // YYYY-MM-DD, defaults to today
+	Note          string  `json:"note" validate:"max=255"`
+}
+
+// GoalProgressResponse is a goal with its savings and projections.
+// ProjectedCompletionDate is based on the average contribution rate since the
+// first contribution and is omitted while nothing has been saved.
+type GoalProgressResponse struct {
+	store.Goal
+	Saved                      float64 `json:"saved"`
+	Remaining                  float64 `json:"remaining"`
+	PercentComplete            float64 `json:"percentComplete"`
+	Completed                  bool    `json:"completed"`
+	Contributions              int     `json:"contributions"`
+	AverageMonthlyContribution float64 `json:"averageMonthlyContribution"`
+	ProjectedCompletionDate    *string `json:"projectedCompletionDate"`
+	MonthlyAmountNeeded        float64 `json:"monthlyAmountNeeded"`
+	OnTrack                    bool    `json:"onTrack"`
+}
+
+// goalProgress computes the progress of a goal on the given day.
+func goalProgress(goal *store.Goal, savings *store.GoalSavings, now time.Time) GoalProgressResponse {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	saved := toCents(savings.Saved)
+	remaining := max(toCents(goal.TargetAmount)-saved, 0)
+
+	progress := GoalProgressResponse{
+		Goal:            *goal,
+		Saved:           fromCents(saved),
+		Remaining:       fromCents(remaining),
+		PercentComplete: math.Round(savings.Saved*10000/goal.TargetAmount) / 100,
+		Completed:       remaining == 0,
+		Contributions:   savings.Contributions,
+	}
+
+	var daily float64
+	if savings.FirstContribution != nil && saved > 0 {
+		if first, err := time.Parse(time.DateOnly, *savings.FirstContribution); err == nil {
+			// count both the first day and today
+			days := max(today.Sub(first).Hours()/24+1, 1)
+			daily = float64(saved) / days
+			progress.AverageMonthlyContribution = fromCents(int64(math.Round(daily * daysPerMonth)))
+		}
+	}
+	if !progress.Completed && daily > 0 {
+		projected := today.AddDate(0, 0, int(math.Ceil(float64(remaining)/daily))).Format(time.DateOnly)
+		progress.ProjectedCompletionDate = &projected
+	}
+
+	progress.MonthlyAmountNeeded = fromCents(remaining)
+	if target, err := time.Parse(time.DateOnly, goal.TargetDate); err == nil {
+		if target.After(today) {
+			months := max(math.Ceil(target.Sub(today).Hours()/24/daysPerMonth), 1)
+			progress.MonthlyAmountNeeded = fromCents(int64(math.Ceil(float64(remaining) / months)))
+		}
+		progress.OnTrack = progress.Completed ||
+			(progress.ProjectedCompletionDate != nil && *progress.ProjectedCompletionDate <= goal.TargetDate)
+	}
+	return progress
+}
+
+// createGoalHandler godoc
+//
+//	@Summary		Create a savings goal
+//	@Description	Create a savings goal. Transactions of the optional linked category and its sub-categories count towards the goal.
+//	@Tags			goals
+//	@Accept			json
+//	@Produce		json
+//	@Param			goal	body		CreateGoalRequest	true	"Goal data"
+//	@Success		201		{object}	store.Goal
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/goals [post]
+func (app *application) createGoalHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateGoalRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if _, err := time.Parse(time.DateOnly, payload.TargetDate); err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid targetDate, expected YYYY-MM-DD: %s", payload.TargetDate))
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	goal := &store.Goal{
+		UserID:       user.ID,
+		Name:         payload.Name,
+		TargetAmount: payload.TargetAmount,
+		TargetDate:   payload.TargetDate,
+	}
+	if payload.CategoryID != nil {
+		if err := app.linkGoalCategory(ctx, goal, *payload.CategoryID); err != nil {
+			if err == store.ErrNotFound {
+				app.badRequestResponse(w, r, errors.New("category not found"))
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.store.Goals.Create(ctx, goal); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, goal); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("goal created", "goal", goal.ID, "user", user.ID)
+}
+
+// linkGoalCategory links the goal to a category the user can see, or removes
+// the link when categoryID is 0.
+func (app *application) linkGoalCategory(ctx context.Context, goal *store.Goal, categoryID int64) error {
+	if categoryID == 0 {
+		goal.CategoryID = nil
+		goal.CategoryName = ""
+		return nil
+	}
+	category, err := app.store.Category.GetByID(ctx, categoryID)
+	if err != nil {
+		return err
+	}
+	if !categoryVisibleTo(category, goal.UserID) {
+		return store.ErrNotFound
+	}
+	goal.CategoryID = &category.ID
+	goal.CategoryName = category.Name
+	return nil
+}
+
+// listGoalsHandler godoc
+//
+//	@Summary		List savings goals
+//	@Description	List the authenticated user's savings goals, nearest target date first
+//	@Tags			goals
+//	@Produce		json
+//	@Success		200	{object}	[]store.Goal
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/goals [get]
+func (app *application) listGoalsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	goals, err := app.store.Goals.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, goals); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getGoalHandler godoc
+//
+//	@Summary		Get a savings goal
+//	@Description	Get a goal with the amount saved, the projected completion date at the average contribution rate and the monthly amount needed to reach the target date
+//	@Tags			goals
+//	@Produce		json
+//	@Param			id	path		int	true	"Goal ID"
+//	@Success		200	{object}	GoalProgressResponse
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/goals/{id} [get]
+func (app *application) getGoalHandler(w http.ResponseWriter, r *http.Request) {
+	goal := getGoalFromContext(r)
+	savings, err := app.store.Goals.Savings(r.Context(), goal.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, goalProgress(goal, savings, time.Now())); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// updateGoalHandler godoc
+//
+//	@Summary		Update a savings goal
+//	@Description	Change the name, target or linked category of a goal. A categoryId of 0 removes the link.
+//	@Tags			goals
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Goal ID"
+//	@Param			goal	body		UpdateGoalRequest	true	"Goal data"
+//	@Success		200		{object}	store.Goal
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/goals/{id} [patch]
+func (app *application) updateGoalHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateGoalRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	goal := getGoalFromContext(r)
+	if payload.Name != nil {
+		if *payload.Name == "" {
+			app.badRequestResponse(w, r, errors.New("name must not be empty"))
+			return
+		}
+		goal.Name = *payload.Name
+	}
+	if payload.TargetAmount != nil {
+		goal.TargetAmount = *payload.TargetAmount
+	}
+	if payload.TargetDate != nil {
+		if _, err := time.Parse(time.DateOnly, *payload.TargetDate); err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid targetDate, expected YYYY-MM-DD: %s", *payload.TargetDate))
+			return
+		}
+		goal.TargetDate = *payload.TargetDate
+	}
+	if payload.CategoryID != nil {
+		if err := app.linkGoalCategory(ctx, goal, *payload.CategoryID); err != nil {
+			if err == store.ErrNotFound {
+				app.badRequestResponse(w, r, errors.New("category not found"))
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.store.Goals.Update(ctx, goal); err != nil {
+		if err == store.ErrNotFound {
+			app.notFoundResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, goal); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("goal updated", "goal", goal.ID)
+}
+
+// deleteGoalHandler godoc
+//
+//	@Summary		Delete a savings goal
+//	@Description	Delete a goal and its deposits. Tagged transactions are kept.
+//	@Tags			goals
+//	@Produce		json
+//	@Param			id	path		int	true	"Goal ID"
+//	@Success		204	{object}	nil
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/goals/{id} [delete]
+func (app *application) deleteGoalHandler(w http.ResponseWriter, r *http.Request) {
+	goal := getGoalFromContext(r)
+	if err := app.store.Goals.Delete(r.Context(), goal.ID); err != nil {
+		if err == store.ErrNotFound {
+			app.notFoundResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("goal deleted", "goal", goal.ID)
+}
+
+// listGoalContributionsHandler godoc
+//
+//	@Summary		List goal contributions
+//	@Description	List the deposits, tagged transactions and linked category transactions of a goal, oldest first
+//	@Tags			goals
+//	@Produce		json
+//	@Param			id	path		int	true	"Goal ID"
+//	@Success		200	{object}	[]store.GoalContribution
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/goals/{id}/contributions [get]
+func (app *application) listGoalContributionsHandler(w http.ResponseWriter, r *http.Request) {
+	goal := getGoalFromContext(r)
+	contributions, err := app.store.Goals.ListContributions(r.Context(), goal.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, contributions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// createGoalContributionHandler godoc
+//
+//	@Summary		Contribute to a goal
+//	@Description	Record a deposit towards a goal, or tag one of the user's transactions with it
+//	@Tags			goals
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int								true	"Goal ID"
+//	@Param			contribution	body		CreateGoalContributionRequest	true	"Deposit or transaction"
+//	@Success		201				{object}	store.GoalContribution
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		409				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/goals/{id}/contributions [post]
+func (app *application) createGoalContributionHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateGoalContributionRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	goal := getGoalFromContext(r)
+	contribution := &store.GoalContribution{GoalID: goal.ID, Note: payload.Note}
+	if payload.TransactionID != nil {
+		if payload.Amount != 0 || payload.Date != "" {
+			app.badRequestResponse(w, r, errors.New("a contribution is either a deposit or a transaction, not both"))
+			return
+		}
+		transaction, err := app.store.Transactions.GetByID(ctx, *payload.TransactionID)
+		if err != nil {
+			if err == store.ErrNotFound {
+				app.badRequestResponse(w, r, errors.New("transaction not found"))
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+		if transaction.UserID != user.ID {
+			app.badRequestResponse(w, r, errors.New("transaction not found"))
+			return
+		}
+		contribution.TransactionID = &transaction.ID
+		contribution.Amount = transaction.Amount
+		contribution.Date = exportDate(transaction.TransactionDate)
+		if contribution.Note == "" {
+			contribution.Note = transaction.Description
+		}
+	} else {
+		contribution.Amount = payload.Amount
+		contribution.Date = payload.Date
+		if contribution.Date == "" {
+			contribution.Date = time.Now().Format(time.DateOnly)
+		} else if _, err := time.Parse(time.DateOnly, contribution.Date); err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid date, expected YYYY-MM-DD: %s", contribution.Date))
+			return
+		}
+	}
+
+	if err := app.store.Goals.AddContribution(ctx, contribution); err != nil {
+		if err == store.ErrDuplicateGoalContribution {
+			app.conflictResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, contribution); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("goal contribution added", "goal", goal.ID, "contribution", contribution.ID, "source", contribution.Source)
+}
+
+// deleteGoalContributionHandler godoc
+//
+//	@Summary		Remove a goal contribution
+//	@Description	Delete a deposit or untag a transaction. Transactions of the linked category cannot be removed this way.
+//	@Tags			goals
+//	@Produce		json
+//	@Param			id				path		int	true	"Goal ID"
+//	@Param			contributionId	path		int	true	"Contribution ID"
+//	@Success		204				{object}	nil
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/goals/{id}/contributions/{contributionId} [delete]
+func (app *application) deleteGoalContributionHandler(w http.ResponseWriter, r *http.Request) {
+	contributionID := chi.URLParam(r, "contributionId")
+	contributionIDInt, err := strconv.ParseInt(contributionID, 10, 64)
+	if err != nil || contributionIDInt <= 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid contribution ID: %s", contributionID))
+		return
+	}
+
+	goal := getGoalFromContext(r)
+	if err := app.store.Goals.DeleteContribution(r.Context(), goal.ID, contributionIDInt); err != nil {
+		if err == store.ErrNotFound {
+			app.notFoundResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("goal contribution removed", "goal", goal.ID, "contribution", contributionIDInt)
+}
+
+func (app *application) goalContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		goalID := chi.URLParam(r, "id")
+		goalIDInt, err := strconv.ParseInt(goalID, 10, 64)
+		if err != nil || goalIDInt <= 0 {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid goal ID: %s", goalID))
+			return
+		}
+
+		ctx := r.Context()
+		goal, err := app.store.Goals.GetByID(ctx, goalIDInt)
+		if err != nil {
+			if err == store.ErrNotFound {
+				app.notFoundResponse(w, r, err)
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+		ctx = context.WithValue(r.Context(), goalCtx, goal)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getGoalFromContext(r *http.Request) *store.Goal {
+	goal, _ := r.Context().Value(goalCtx).(*store.Goal)
+	return goal
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+type CreateGroupTransactionRequest struct {
+	CreateTransactionRequest
+	// Split defaults to an equal split between all group members when omitted.
+	Split *SplitRequest `json:"split"`
+}
+
+// createGroupTransactionHandler godoc
+//
+//	@Summary		Create a new group transaction
+//	@Description	Create a new transaction in a group the authenticated user is a member of
+//	@Tags			groups
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int								true	"Group ID"
+//	@Param			transaction	body		CreateGroupTransactionRequest	true	"Transaction data"
+//	@Success		201			{object}	store.GroupTransaction
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/groups/{id}/transactions [post]
+func (app *application) createGroupTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateGroupTransactionRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if payload.TransactionType != "income" && payload.TransactionType != "expense" {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid transaction type: %s", payload.TransactionType))
+		return
+	}
+	if payload.Amount <= 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("amount must be greater than zero"))
+		return
+	}
+
+	parsedTime, err := time.Parse(time.RFC3339, payload.TransactionDate)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	categoryDetails, err := app.store.Category.GetByName(ctx, user.ID, payload.CategoryName)
+	if err != nil {
+		if err == store.ErrNotFound {
+			app.badRequestResponse(w, r, fmt.Errorf("category not found"))
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	group := getGroupFromContext(r)
+	splitRequest := SplitRequest{Type: store.SplitEqual}
+	if payload.Split != nil {
+		splitRequest = *payload.Split
+	}
+	splits, err := computeSplits(payload.Amount, splitRequest, group)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	transaction := &store.GroupTransaction{
+		GroupID:         group.ID,
+		UserID:          user.ID,
+		CreatedBy:       user.Username,
+		Amount:          payload.Amount,
+		TransactionType: payload.TransactionType,
+		Description:     payload.Description,
+		CategoryID:      categoryDetails.ID,
+		CategoryName:    categoryDetails.Name,
+		TransactionDate: parsedTime.Format(time.RFC3339),
+		SplitType:       splitRequest.Type,
+		Splits:          splits,
+	}
+
+	transactionData, err := app.store.GroupTransactions.Create(ctx, transaction)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, transactionData); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("group transaction created", "group", group.ID, "transaction", transactionData.ID)
+}
+
+// listGroupTransactionsHandler godoc
+//
+//	@Summary		List group transactions
+//	@Description	List the transactions of a group the authenticated user is a member of
+//	@Tags			groups
+//	@Produce		json
+//	@Param			id	path		int	true	"Group ID"
+//	@Success		200	{array}		store.GroupTransaction
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/groups/{id}/transactions [get]
+func (app *application) listGroupTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	group := getGroupFromContext(r)
+
+	transactions, err := app.store.GroupTransactions.ListByGroup(r.Context(), group.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, transactions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getGroupTransactionHandler godoc
+//
+//	@Summary		Get a group transaction by ID
+//	@Description	Get a transaction of a group the authenticated user is a member of
+//	@Tags			groups
+//	@Produce		json
+//	@Param			id				path		int	true	"Group ID"
+//	@Param			transactionID	path		int	true	"Transaction ID"
+//	@Success		200				{object}	store.GroupTransaction
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/groups/{id}/transactions/{transactionID} [get]
+func (app *application) getGroupTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	transaction := getGroupTransactionFromContext(r)
+	if err := app.jsonResponse(w, http.StatusOK, transaction); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type UpdateGroupTransactionRequest struct {
+	CreateTransactionRequest
+	// Split keeps the transaction's current split when omitted.
+	Split *SplitRequest `json:"split"`
+}
+
+// updateGroupTransactionHandler godoc
+//
+//	@Summary		Update a group transaction by ID
+//	@Description	Update a transaction of a group the authenticated user is a member of
+//	@Tags			groups
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int								true	"Group ID"
+//	@Param			transactionID	path		int								true	"Transaction ID"
+//	@Param			transaction		body		UpdateGroupTransactionRequest	true	"Transaction data"
+//	@Success		200				{object}	store.GroupTransaction
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/groups/{id}/transactions/{transactionID} [patch]
+func (app *application) updateGroupTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateGroupTransactionRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if payload.TransactionType != "income" && payload.TransactionType != "expense" {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid transaction type: %s", payload.TransactionType))
+		return
+	}
+	if payload.Amount <= 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("amount must be greater than zero"))
+		return
+	}
+
+	parsedTime, err := time.Parse(time.RFC3339, payload.TransactionDate)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	categoryDetails, err := app.store.Category.GetByName(ctx, user.ID, payload.CategoryName)
+	if err != nil {
+		if err == store.ErrNotFound {
+			app.badRequestResponse(w, r, fmt.Errorf("category not found"))
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	transaction := getGroupTransactionFromContext(r)
+	if transaction.TransactionType == store.TransactionTypeSettlement {
+		app.badRequestResponse(w, r, fmt.Errorf("settlements cannot be edited, delete and record it again instead"))
+		return
+	}
+	// a new split has to be among current members, the stored one may keep
+	// members who left since
+	group := getGroupFromContext(r)
+	splitRequest := splitRequestFromTransaction(transaction)
+	if payload.Split != nil {
+		splitRequest = *payload.Split
+	} else {
+		group = withSplitParticipants(group, transaction)
+	}
+	splits, err := computeSplits(payload.Amount, splitRequest, group)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	transaction.Amount = payload.Amount
+	transaction.TransactionType = payload.TransactionType
+	transaction.Description = payload.Description
+	transaction.CategoryID = categoryDetails.ID
+	transaction.CategoryName = categoryDetails.Name
+	transaction.TransactionDate = parsedTime.Format(time.RFC3339)
+	transaction.SplitType = splitRequest.Type
+	transaction.Splits = splits
+
+	if err := app.store.GroupTransactions.Update(ctx, transaction); err != nil {
+		if err == store.ErrNotFound {
+			app.notFoundResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, transaction); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("group transaction updated", "group", transaction.GroupID, "transaction", transaction.ID)
+}
+
+// deleteGroupTransactionHandler godoc
+//
+//	@Summary		Delete a group transaction by ID
+//	@Description	Delete a transaction of a group the authenticated user is a member of
+//	@Tags			groups
+//	@Produce		json
+//	@Param			id				path		int	true	"Group ID"
+//	@Param			transactionID	path		int	true	"Transaction ID"
+//	@Success		204				{object}	nil
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/groups/{id}/transactions/{transactionID} [delete]
+func (app *application) deleteGroupTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	transaction := getGroupTransactionFromContext(r)
+	if err := app.store.GroupTransactions.DeleteByID(r.Context(), transaction.ID); err != nil {
+		if err == store.ErrNotFound {
+			app.notFoundResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("group transaction deleted", "group", transaction.GroupID, "transaction", transaction.ID)
+}
+
+func (app *application) groupTransactionContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		transactionID := chi.URLParam(r, "transactionID")
+		transactionIDInt, err := strconv.ParseInt(transactionID, 10, 64)
+		if err != nil || transactionIDInt <= 0 {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid transaction ID: %s", transactionID))
+			return
+		}
+
+		ctx := r.Context()
+		transaction, err := app.store.GroupTransactions.GetByID(ctx, transactionIDInt)
+		if err != nil {
+			if err == store.ErrNotFound {
+				app.notFoundResponse(w, r, err)
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		// a transaction from another group is reported as missing rather than leaking its existence
+		group := getGroupFromContext(r)
+		if transaction.GroupID != group.ID {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+
+		ctx = context.WithValue(r.Context(), groupTransactionCtx, transaction)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getGroupTransactionFromContext(r *http.Request) *store.GroupTransaction {
+	transaction, _ := r.Context().Value(groupTransactionCtx).(*store.GroupTransaction)
+	return transaction
+}
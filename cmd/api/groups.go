@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+type CreateGroupRequest struct {
+	Name    string   `json:"name" validate:"required,max=100"`
+	Members []string `json:"members" validate:"omitempty,dive,email,max=255"`
+}
+
+// createGroupHandler godoc
+//
+//	@Summary		Create a new group
+//	@Description	Create a new group with the authenticated user as its owner. Members are looked up by email.
+//	@Tags			groups
+//	@Accept			json
+//	@Produce		json
+//	@Param			group	body		CreateGroupRequest	true	"Group data"
+//	@Success		201		{object}	store.Group
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/groups [post]
+func (app *application) createGroupHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateGroupRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	memberIDs := make([]int64, 0, len(payload.Members))
+	for _, email := range payload.Members {
+		member, err := app.store.Users.GetByEmail(ctx, email)
+		if err != nil {
+			if err == store.ErrNotFound {
+				app.badRequestResponse(w, r, fmt.Errorf("user not found: %s", email))
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+		memberIDs = append(memberIDs, member.ID)
+	}
+
+	group := &store.Group{
+		Name:      payload.Name,
+		CreatedBy: user.ID,
+	}
+	if err := app.store.Groups.Create(ctx, group, memberIDs); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	group, err := app.store.Groups.GetByID(ctx, group.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, group); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("group created", "group", group.ID, "user", user.ID)
+}
+
+// listGroupsHandler godoc
+//
+//	@Summary		List groups for the authenticated user
+//	@Description	List the groups the authenticated user is a member of
+//	@Tags			groups
+//	@Produce		json
+//	@Success		200	{array}		store.Group
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/groups [get]
+func (app *application) listGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	groups, err := app.store.Groups.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, groups); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getGroupHandler godoc
+//
+//	@Summary		Get a group by ID
+//	@Description	Get a group and its members. Only members can see a group.
+//	@Tags			groups
+//	@Produce		json
+//	@Param			id	path		int	true	"Group ID"
+//	@Success		200	{object}	store.Group
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/groups/{id} [get]
+func (app *application) getGroupHandler(w http.ResponseWriter, r *http.Request) {
+	group := getGroupFromContext(r)
+	if err := app.jsonResponse(w, http.StatusOK, group); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type UpdateGroupRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// updateGroupHandler godoc
+//
+//	@Summary		Rename a group
+//	@Description	Rename a group the authenticated user is a member of
+//	@Tags			groups
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Group ID"
+//	@Param			group	body		UpdateGroupRequest	true	"Group data"
+//	@Success		200		{object}	store.Group
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/groups/{id} [patch]
+func (app *application) updateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateGroupRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	group := getGroupFromContext(r)
+	group.Name = payload.Name
+	if err := app.store.Groups.Update(r.Context(), group); err != nil {
+		if err == store.ErrNotFound {
+			app.notFoundResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, group); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("group renamed", "group", group.ID, "name", group.Name)
+}
+
+// deleteGroupHandler godoc
+//
+//	@Summary		Delete a group
+//	@Description	Delete a group. Only the user who created the group can delete it.
+//	@Tags			groups
+//	@Produce		json
+//	@Param			id	path		int	true	"Group ID"
+//	@Success		204	{object}	nil
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/groups/{id} [delete]
+func (app *application) deleteGroupHandler(w http.ResponseWriter, r *http.Request) {
+	group := getGroupFromContext(r)
+	if err := app.store.Groups.Delete(r.Context(), group.ID); err != nil {
+		if err == store.ErrNotFound {
+			app.notFoundResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("group deleted", "group", group.ID)
+}
+
+type AddGroupMemberRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// addGroupMemberHandler godoc
+//
+//	@Summary		Add a member to a group
+//	@Description	Add an existing user, looked up by email, to a group
+//	@Tags			groups
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Group ID"
+//	@Param			member	body		AddGroupMemberRequest	true	"Member data"
+//	@Success		201		{object}	store.Group
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/groups/{id}/members [post]
+func (app *application) addGroupMemberHandler(w http.ResponseWriter, r *http.Request) {
+	var payload AddGroupMemberRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	group := getGroupFromContext(r)
+	ctx := r.Context()
+
+	member, err := app.store.Users.GetByEmail(ctx, payload.Email)
+	if err != nil {
+		if err == store.ErrNotFound {
+			app.notFoundResponse(w, r, fmt.Errorf("user not found: %s", payload.Email))
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Groups.AddMember(ctx, group.ID, member.ID); err != nil {
+		if err == store.ErrAlreadyGroupMember {
+			app.conflictResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	group, err = app.store.Groups.GetByID(ctx, group.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, group); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("group member added", "group", group.ID, "user", member.ID)
+}
+
+// removeGroupMemberHandler godoc
+//
+//	@Summary		Remove a member from a group
+//	@Description	Remove a member from a group. The group creator can remove anyone; other members can only remove themselves.
+//	@Tags			groups
+//	@Produce		json
+//	@Param			id		path		int	true	"Group ID"
+//	@Param			userID	path		int	true	"User ID"
+//	@Success		204		{object}	nil
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/groups/{id}/members/{userID} [delete]
+func (app *application) removeGroupMemberHandler(w http.ResponseWriter, r *http.Request) {
+	memberID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || memberID <= 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid user ID: %s", chi.URLParam(r, "userID")))
+		return
+	}
+
+	group := getGroupFromContext(r)
+	user := getUserFromContext(r)
+	if memberID != user.ID && group.CreatedBy != user.ID {
+		app.unauthorizedErrorResponse(w, r, errors.New("only the group creator can remove other members"))
+		return
+	}
+	if memberID == group.CreatedBy {
+		app.badRequestResponse(w, r, errors.New("the group creator cannot be removed, delete the group instead"))
+		return
+	}
+
+	if err := app.store.Groups.RemoveMember(r.Context(), group.ID, memberID); err != nil {
+		if err == store.ErrNotGroupMember {
+			app.notFoundResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("group member removed", "group", group.ID, "user", memberID)
+}
+
+func (app *application) groupContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		groupID := chi.URLParam(r, "id")
+		groupIDInt, err := strconv.ParseInt(groupID, 10, 64)
+		if err != nil || groupIDInt <= 0 {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid group ID: %s", groupID))
+			return
+		}
+
+		ctx := r.Context()
+		group, err := app.store.Groups.GetByID(ctx, groupIDInt)
+		if err != nil {
+			if err == store.ErrNotFound {
+				app.notFoundResponse(w, r, err)
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+		ctx = context.WithValue(r.Context(), groupCtx, group)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getGroupFromContext(r *http.Request) *store.Group {
+	group, _ := r.Context().Value(groupCtx).(*store.Group)
+	return group
+}
@@ -0,0 +1,606 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sumit8974/finance-tracker/internal/statement"
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+const (
+	maxImportFileSize = 10 << 20 // 10mb
+	maxImportRows     = 5000
+	// maxImportAmount keeps imported amounts within the amount column's
+	// decimal(15,2)
+	maxImportAmount = 1e13
+
+	SignNegativeExpense = "negative-expense"
+	SignNegativeIncome  = "negative-income"
+
+	ImportFormatCSV = "csv"
+	ImportFormatOFX = "ofx"
+	ImportFormatQFX = "qfx"
+	ImportFormatQIF = "qif"
+)
+
+// ImportMapping describes how a bank statement maps to transactions. The
+// column fields only apply to CSV files, whose columns are referenced by header
+// name, or by 1-based position when the file has no header row. OFX/QFX and QIF
+// files have a fixed layout and only use DefaultCategory, and QIF DateFormat.
+type ImportMapping struct {
+	Format            string `validate:"oneof=csv ofx qfx qif"`
+	DateColumn        string `validate:"required_if=Format csv"`
+	AmountColumn      string `validate:"required_if=Format csv"`
+	DescriptionColumn string
+	// TypeColumn holds income/expense (or credit/debit). Without it the type is
+	// taken from the sign of the amount according to SignConvention.
+	TypeColumn     string
+	SignConvention string `validate:"omitempty,oneof=negative-expense negative-income"`
+	// CategoryColumn holds a category name per row; DefaultCategory is used for
+	// rows without one.
+	CategoryColumn  string
+	DefaultCategory string
+	// DateFormat is a Go layout or a pattern such as DD/MM/YYYY.
+	DateFormat       string `validate:"required"`
+	DecimalSeparator string
+	Delimiter        string `validate:"omitempty,len=1"`
+	HasHeader        bool
+}
+
+// ImportRow is one parsed row or statement entry. Row is the line number in the
+// file; rows with Errors are not imported and Duplicate rows were imported
+// before and are skipped.
+type ImportRow struct {
+	Row             int      `json:"row"`
+	TransactionDate string   `json:"transactionDate"`
+	Amount          float64  `json:"amount"`
+	TransactionType string   `json:"transactionType"`
+	Description     string   `json:"description"`
+	CategoryName    string   `json:"categoryName"`
+	ExternalID      string   `json:"externalId,omitempty"`
+	Duplicate       bool     `json:"duplicate,omitempty"`
+	Errors          []string `json:"errors,omitempty"`
+}
+
+type ImportPreviewResponse struct {
+	Rows          []ImportRow `json:"rows"`
+	TotalRows     int         `json:"totalRows"`
+	ValidRows     int         `json:"validRows"`
+	InvalidRows   int         `json:"invalidRows"`
+	DuplicateRows int         `json:"duplicateRows"`
+}
+
+type ImportConfirmResponse struct {
+	Imported     int                  `json:"imported"`
+	Skipped      int                  `json:"skipped"`
+	Transactions []*store.Transaction `json:"transactions"`
+}
+
+// importTransactionsHandler godoc
+//
+//	@Summary		Preview a statement import
+//	@Description	Parse a bank statement (CSV with the given column mapping, OFX/QFX or QIF) and return the transactions it would create, with validation errors per row and the rows that were already imported. Nothing is stored.
+//	@Tags			transactions
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file				formData	file	true	"Statement file"
+//	@Param			format				formData	string	false	"csv (default), ofx, qfx or qif"
+//	@Param			dateColumn			formData	string	false	"Date column, required for CSV"
+//	@Param			amountColumn		formData	string	false	"Amount column, required for CSV"
+//	@Param			descriptionColumn	formData	string	false	"Description column"
+//	@Param			typeColumn			formData	string	false	"Column with income/expense or credit/debit"
+//	@Param			signConvention		formData	string	false	"negative-expense (default) or negative-income, used without a type column"
+//	@Param			categoryColumn		formData	string	false	"Category name column"
+//	@Param			defaultCategory		formData	string	false	"Category for rows without one"
+//	@Param			dateFormat			formData	string	false	"Date format of CSV and QIF files, e.g. YYYY-MM-DD (CSV default), MM/DD/YYYY (QIF default) or DD/MM/YYYY"
+//	@Param			decimalSeparator	formData	string	false	". (default) or ,"
+//	@Param			delimiter			formData	string	false	"Field delimiter, defaults to ,"
+//	@Param			hasHeader			formData	bool	false	"Whether the first line is a header, defaults to true"
+//	@Success		200					{object}	ImportPreviewResponse
+//	@Failure		400					{object}	error
+//	@Failure		401					{object}	error
+//	@Failure		500					{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/transactions/import [post]
+func (app *application) importTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := parseImport(w, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	preview, _, err := app.resolveImportRows(r, rows)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, preview); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// confirmImportTransactionsHandler godoc
+//
+//	@Summary		Import a statement
+//	@Description	Parse a bank statement exactly like the preview and store all of its transactions at once, skipping the ones already imported. If any row is invalid nothing is stored and the preview is returned.
+//	@Tags			transactions
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file				formData	file	true	"Statement file"
+//	@Param			format				formData	string	false	"csv (default), ofx, qfx or qif"
+//	@Param			dateColumn			formData	string	false	"Date column, required for CSV"
+//	@Param			amountColumn		formData	string	false	"Amount column, required for CSV"
+//	@Param			descriptionColumn	formData	string	false	"Description column"
+//	@Param			typeColumn			formData	string	false	"Column with income/expense or credit/debit"
+//	@Param			signConvention		formData	string	false	"negative-expense (default) or negative-income, used without a type column"
+//	@Param			categoryColumn		formData	string	false	"Category name column"
+//	@Param			defaultCategory		formData	string	false	"Category for rows without one"
+//	@Param			dateFormat			formData	string	false	"Date format of CSV and QIF files, e.g. YYYY-MM-DD (CSV default), MM/DD/YYYY (QIF default) or DD/MM/YYYY"
+//	@Param			decimalSeparator	formData	string	false	". (default) or ,"
+//	@Param			delimiter			formData	string	false	"Field delimiter, defaults to ,"
+//	@Param			hasHeader			formData	bool	false	"Whether the first line is a header, defaults to true"
+//	@Success		201					{object}	ImportConfirmResponse
+//	@Failure		400					{object}	error
+//	@Failure		401					{object}	error
+//	@Failure		422					{object}	ImportPreviewResponse
+//	@Failure		500					{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/transactions/import/confirm [post]
+func (app *application) confirmImportTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := parseImport(w, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	preview, transactions, err := app.resolveImportRows(r, rows)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if preview.InvalidRows > 0 {
+		if err := app.jsonResponse(w, http.StatusUnprocessableEntity, preview); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if len(transactions) == 0 && preview.DuplicateRows == 0 {
+		app.badRequestResponse(w, r, errors.New("the file contains no transactions"))
+		return
+	}
+
+	created, err := app.store.Transactions.CreateMany(r.Context(), transactions)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	skipped := preview.DuplicateRows + len(transactions) - len(created)
+	if err := app.jsonResponse(w, http.StatusCreated, ImportConfirmResponse{
+		Imported:     len(created),
+		Skipped:      skipped,
+		Transactions: created,
+	}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("transactions imported", "user", getUserFromContext(r).ID, "count", len(created), "skipped", skipped)
+	app.flagAnomalies(created)
+}
+
+// parseImport reads the uploaded statement and its mapping into import rows.
+func parseImport(w http.ResponseWriter, r *http.Request) ([]ImportRow, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportFileSize)
+	if err := r.ParseMultipartForm(maxImportFileSize); err != nil {
+		return nil, err
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, fmt.Errorf("missing file: %w", err)
+	}
+	defer file.Close()
+
+	mapping, err := readImportMapping(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mapping.Format {
+	case ImportFormatOFX, ImportFormatQFX:
+		entries, err := statement.ParseOFX(file)
+		if err != nil {
+			return nil, err
+		}
+		return statementImportRows(entries, mapping)
+	case ImportFormatQIF:
+		entries, err := statement.ParseQIF(file, importDateLayout(mapping.DateFormat))
+		if err != nil {
+			return nil, err
+		}
+		return statementImportRows(entries, mapping)
+	default:
+		return parseCSVImport(file, mapping)
+	}
+}
+
+func readImportMapping(r *http.Request) (ImportMapping, error) {
+	mapping := ImportMapping{
+		Format:            strings.ToLower(r.FormValue("format")),
+		DateColumn:        strings.TrimSpace(r.FormValue("dateColumn")),
+		AmountColumn:      strings.TrimSpace(r.FormValue("amountColumn")),
+		DescriptionColumn: strings.TrimSpace(r.FormValue("descriptionColumn")),
+		TypeColumn:        strings.TrimSpace(r.FormValue("typeColumn")),
+		SignConvention:    r.FormValue("signConvention"),
+		CategoryColumn:    strings.TrimSpace(r.FormValue("categoryColumn")),
+		DefaultCategory:   strings.TrimSpace(r.FormValue("defaultCategory")),
+		DateFormat:        r.FormValue("dateFormat"),
+		DecimalSeparator:  r.FormValue("decimalSeparator"),
+		Delimiter:         r.FormValue("delimiter"),
+		HasHeader:         true,
+	}
+	if mapping.Format == "" {
+		mapping.Format = ImportFormatCSV
+	}
+	if mapping.DateFormat == "" {
+		mapping.DateFormat = time.DateOnly
+		if mapping.Format == ImportFormatQIF {
+			mapping.DateFormat = statement.DefaultQIFDateLayout
+		}
+	}
+	if mapping.SignConvention == "" {
+		mapping.SignConvention = SignNegativeExpense
+	}
+	if mapping.DecimalSeparator == "" {
+		mapping.DecimalSeparator = "."
+	}
+	if mapping.Delimiter == "" {
+		mapping.Delimiter = ","
+	}
+	if hasHeader := r.FormValue("hasHeader"); hasHeader != "" {
+		value, err := strconv.ParseBool(hasHeader)
+		if err != nil {
+			return mapping, fmt.Errorf("invalid hasHeader: %s", hasHeader)
+		}
+		mapping.HasHeader = value
+	}
+	if err := Validate.Struct(mapping); err != nil {
+		return mapping, err
+	}
+	if mapping.DecimalSeparator != "." && mapping.DecimalSeparator != "," {
+		return mapping, fmt.Errorf("invalid decimalSeparator: %s", mapping.DecimalSeparator)
+	}
+	if mapping.Format != ImportFormatCSV && mapping.DefaultCategory == "" {
+		return mapping, errors.New("defaultCategory is required")
+	}
+	if mapping.CategoryColumn == "" && mapping.DefaultCategory == "" {
+		return mapping, errors.New("either categoryColumn or defaultCategory is required")
+	}
+	return mapping, nil
+}
+
+// statementImportRows turns OFX and QIF entries into import rows. Debits become
+// expenses and credits become income.
+func statementImportRows(entries []statement.Entry, mapping ImportMapping) ([]ImportRow, error) {
+	if len(entries) > maxImportRows {
+		return nil, fmt.Errorf("the file has more than %d transactions", maxImportRows)
+	}
+
+	rows := make([]ImportRow, 0, len(entries))
+	for _, entry := range entries {
+		row := ImportRow{
+			Row:             entry.Line,
+			Amount:          math.Abs(entry.Amount),
+			TransactionType: entry.TransactionType(),
+			Description:     entry.Description,
+			CategoryName:    mapping.DefaultCategory,
+			ExternalID:      entry.ExternalID(),
+		}
+		if entry.Err != nil {
+			row.Errors = append(row.Errors, strings.Split(entry.Err.Error(), "\n")...)
+		} else {
+			row.TransactionDate = entry.Date.Format(time.RFC3339)
+			if err := checkImportAmount(entry.Amount); err != nil {
+				row.Errors = append(row.Errors, err.Error())
+			} else if entry.Amount == 0 {
+				row.Errors = append(row.Errors, "amount must not be zero")
+			}
+		}
+		if len(row.Description) > 255 {
+			row.Errors = append(row.Errors, "description must be at most 255 characters")
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseCSVImport turns the CSV into import rows. Errors that concern a single
+// row are recorded on that row; only problems with the file as a whole are
+// returned.
+func parseCSVImport(file io.Reader, mapping ImportMapping) ([]ImportRow, error) {
+	reader := csv.NewReader(file)
+	reader.Comma = rune(mapping.Delimiter[0])
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var header []string
+	if mapping.HasHeader {
+		record, err := reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				return nil, errors.New("the file is empty")
+			}
+			return nil, err
+		}
+		header = record
+	}
+
+	columns := make(map[string]int)
+	for name, column := range map[string]string{
+		"dateColumn":        mapping.DateColumn,
+		"amountColumn":      mapping.AmountColumn,
+		"descriptionColumn": mapping.DescriptionColumn,
+		"typeColumn":        mapping.TypeColumn,
+		"categoryColumn":    mapping.CategoryColumn,
+	} {
+		if column == "" {
+			continue
+		}
+		index, err := resolveImportColumn(header, column)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		columns[name] = index
+	}
+	layout := importDateLayout(mapping.DateFormat)
+
+	rows := []ImportRow{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rows = append(rows, ImportRow{Row: parseErr.StartLine, Errors: []string{parseErr.Err.Error()}})
+				continue
+			}
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if isBlankRecord(record) {
+			continue
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("the file has more than %d rows", maxImportRows)
+		}
+
+		field := func(name string) string {
+			index, ok := columns[name]
+			if !ok || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+
+		row := ImportRow{
+			Row:          line,
+			Description:  field("descriptionColumn"),
+			CategoryName: field("categoryColumn"),
+		}
+		if row.CategoryName == "" {
+			row.CategoryName = mapping.DefaultCategory
+		}
+
+		if date, err := time.Parse(layout, field("dateColumn")); err != nil {
+			row.Errors = append(row.Errors, fmt.Sprintf("invalid date %q, expected format %s", field("dateColumn"), mapping.DateFormat))
+		} else {
+			row.TransactionDate = date.Format(time.RFC3339)
+		}
+
+		amount, err := parseImportAmount(field("amountColumn"), mapping.DecimalSeparator)
+		switch {
+		case errors.Is(err, errImportAmountTooLarge):
+			row.Errors = append(row.Errors, err.Error())
+		case err != nil:
+			row.Errors = append(row.Errors, fmt.Sprintf("invalid amount %q", field("amountColumn")))
+		case amount == 0:
+			row.Errors = append(row.Errors, "amount must not be zero")
+		default:
+			row.Amount = math.Abs(amount)
+			if mapping.TypeColumn != "" {
+				if row.TransactionType = importTransactionType(field("typeColumn")); row.TransactionType == "" {
+					row.Errors = append(row.Errors, fmt.Sprintf("invalid transaction type %q", field("typeColumn")))
+				}
+			} else if (amount < 0) == (mapping.SignConvention == SignNegativeExpense) {
+				row.TransactionType = "expense"
+			} else {
+				row.TransactionType = "income"
+			}
+		}
+
+		if len(row.Description) > 255 {
+			row.Errors = append(row.Errors, "description must be at most 255 characters")
+		}
+		if row.CategoryName == "" {
+			row.Errors = append(row.Errors, "category is missing")
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// resolveImportRows looks up the category of every row, marks the rows that
+// were imported before and builds the transactions for the remaining rows
+// without errors.
+func (app *application) resolveImportRows(r *http.Request, rows []ImportRow) (*ImportPreviewResponse, []*store.Transaction, error) {
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	var externalIDs []string
+	for _, row := range rows {
+		if row.ExternalID != "" {
+			externalIDs = append(externalIDs, row.ExternalID)
+		}
+	}
+	imported := make(map[string]bool)
+	if len(externalIDs) > 0 {
+		var err error
+		if imported, err = app.store.Transactions.ExistingExternalIDs(ctx, user.ID, externalIDs); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	categories := make(map[string]*store.Category)
+	preview := &ImportPreviewResponse{Rows: rows, TotalRows: len(rows)}
+	var transactions []*store.Transaction
+	for i := range rows {
+		row := &rows[i]
+		if row.ExternalID != "" && len(row.Errors) == 0 {
+			// also catches the same entry appearing twice in one file
+			if imported[row.ExternalID] {
+				row.Duplicate = true
+				preview.DuplicateRows++
+				continue
+			}
+			imported[row.ExternalID] = true
+		}
+
+		if row.CategoryName != "" {
+			key := strings.ToLower(row.CategoryName)
+			category, ok := categories[key]
+			if !ok {
+				var err error
+				category, err = app.store.Category.GetByName(ctx, user.ID, row.CategoryName)
+				if err != nil && err != store.ErrNotFound {
+					return nil, nil, err
+				}
+				categories[key] = category
+			}
+			if category == nil {
+				row.Errors = append(row.Errors, fmt.Sprintf("category %q not found", row.CategoryName))
+			} else {
+				row.CategoryName = category.Name
+				if len(row.Errors) == 0 {
+					transactions = append(transactions, &store.Transaction{
+						UserID:          user.ID,
+						Amount:          row.Amount,
+						CategoryID:      category.ID,
+						CategoryName:    category.Name,
+						TransactionType: row.TransactionType,
+						Description:     row.Description,
+						TransactionDate: row.TransactionDate,
+						ExternalID:      row.ExternalID,
+					})
+				}
+			}
+		}
+
+		if len(row.Errors) > 0 {
+			preview.InvalidRows++
+		} else {
+			preview.ValidRows++
+		}
+	}
+	return preview, transactions, nil
+}
+
+func resolveImportColumn(header []string, column string) (int, error) {
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), column) {
+			return i, nil
+		}
+	}
+	if position, err := strconv.Atoi(column); err == nil && position > 0 {
+		return position - 1, nil
+	}
+	return 0, fmt.Errorf("column %q not found", column)
+}
+
+// importDateLayout converts patterns such as DD/MM/YYYY to a Go layout. Go
+// layouts are returned unchanged.
+func importDateLayout(format string) string {
+	return strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02").Replace(format)
+}
+
+var errImportAmountTooLarge = fmt.Errorf("amount must be less than %.0f", maxImportAmount)
+
+// checkImportAmount rejects amounts that can not be stored.
+func checkImportAmount(amount float64) error {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return errors.New("amount must be a finite number")
+	}
+	if math.Abs(amount) >= maxImportAmount {
+		return errImportAmountTooLarge
+	}
+	return nil
+}
+
+// parseImportAmount parses amounts as written by banks, e.g. "-1,234.50",
+// "$ 12.00" or "(45.00)" for a negative amount.
+func parseImportAmount(value, decimalSeparator string) (float64, error) {
+	negative := false
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		negative = true
+		value = value[1 : len(value)-1]
+	}
+
+	thousandsSeparator := ","
+	if decimalSeparator == "," {
+		thousandsSeparator = "."
+	}
+	value = strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r == '-', r == '+':
+			return r
+		case string(r) == decimalSeparator:
+			return '.'
+		case string(r) == thousandsSeparator, r == ' ', r == '\u00a0':
+			return -1
+		case strings.ContainsRune("$€£¥₹", r):
+			return -1
+		}
+		return r
+	}, value)
+
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkImportAmount(amount); err != nil {
+		return 0, err
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+func importTransactionType(value string) string {
+	switch strings.ToLower(value) {
+	case "income", "credit", "cr", "deposit":
+		return "income"
+	case "expense", "debit", "dr", "withdrawal":
+		return "expense"
+	}
+	return ""
+}
+
+func isBlankRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -77,9 +77,10 @@ func main() {
 				pass: env.GetString("AUTH_BASIC_PASS", "admin"),
 			},
 			token: tokenConfig{
-				secret: env.GetString("AUTH_TOKEN_SECRET", "example"),
-				exp:    time.Hour * 24 * 3, // 3 days
-				iss:    "finance-tracker",
+				secret:     env.GetString("AUTH_TOKEN_SECRET", "example"),
+				exp:        time.Minute * time.Duration(env.GetInt("AUTH_TOKEN_EXP_MINUTES", 15)),
+				refreshExp: time.Hour * time.Duration(env.GetInt("AUTH_REFRESH_TOKEN_EXP_HOURS", 24*30)),
+				iss:        "finance-tracker",
 			},
 		},
 		rateLimiter: ratelimiter.RateLimiterConfig{
@@ -87,6 +88,18 @@ func main() {
 			TimeFrame:            time.Second * 5,
 			Enabled:              env.GetBool("RATE_LIMITER_ENABLED", true),
 		},
+		budgetAlerts: budgetAlertConfig{
+			thresholds: parseBudgetAlertThresholds(env.GetString("BUDGET_ALERT_THRESHOLDS", "80,100")),
+		},
+		scheduler: schedulerConfig{
+			interval: time.Second * time.Duration(env.GetInt("SCHEDULER_INTERVAL_SECONDS", 60)),
+		},
+		anomalies: store.AnomalyRules{
+			StdDevs:           float64(env.GetInt("ANOMALY_STD_DEVS", 3)),
+			WindowDays:        env.GetInt("ANOMALY_WINDOW_DAYS", 90),
+			MinSamples:        env.GetInt("ANOMALY_MIN_SAMPLES", 5),
+			NewMerchantAmount: float64(env.GetInt("ANOMALY_NEW_MERCHANT_AMOUNT", 5000)),
+		},
 	}
 
 	// Main Database
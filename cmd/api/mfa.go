@@ -0,0 +1,503 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sumit8974/finance-tracker/internal/store"
+	"github.com/sumit8974/finance-tracker/internal/totp"
+)
+
+const (
+	// mfaChallengeType marks challenge tokens. They carry no session, so
+	// AuthTokenMiddleware refuses them.
+	mfaChallengeType = "mfa_required"
+	mfaChallengeExp  = 5 * time.Minute
+	// mfaMaxAttempts failed attempts at the second factor lock it for
+	// mfaLockout.
+	mfaMaxAttempts   = 5
+	mfaLockout       = 15 * time.Minute
+	mfaIssuer        = "FinTracker"
+	recoveryCodeSize = 10
+	recoveryCodes    = 10
+)
+
+var errInvalidSecondFactor = errors.New("invalid authentication code")
+
+// secondFactorLockedError is returned by checkSecondFactor while too many
+// failed attempts keep the second factor locked.
+type secondFactorLockedError struct {
+	until time.Time
+}
+
+func (e *secondFactorLockedError) Error() string {
+	return "too many failed attempts, try again later"
+}
+
+// secondFactorLockedResponse answers requests made while the second factor is
+// locked.
+func (app *application) secondFactorLockedResponse(w http.ResponseWriter, r *http.Request, err *secondFactorLockedError) {
+	app.rateLimitExceededResponse(w, r, time.Until(err.until).Round(time.Second).String())
+}
+
+type MFAChallengeResponse struct {
+	MFARequired    bool      `json:"mfaRequired"`
+	ChallengeToken string    `json:"challengeToken"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+}
+
+type MFAStatusResponse struct {
+	Enabled                bool `json:"enabled"`
+	RemainingRecoveryCodes int  `json:"remainingRecoveryCodes"`
+}
+
+type EnrollTOTPResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type VerifyTOTPPayload struct {
+	Code string `json:"code" validate:"required,max=10"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// SecondFactorPayload takes either a code from the authenticator app or one of
+// the recovery codes.
+type SecondFactorPayload struct {
+	Code         string `json:"code" validate:"required_without=RecoveryCode,max=10"`
+	RecoveryCode string `json:"recoveryCode" validate:"required_without=Code,max=20"`
+}
+
+type MFALoginPayload struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	SecondFactorPayload
+}
+
+// mfaChallengeToken signs the short-lived token a login with a correct
+// password gets when the user has two-factor authentication enabled. Its jti
+// is stored so that mfaLoginHandler accepts it only once.
+func (app *application) mfaChallengeToken(ctx context.Context, user *store.User, device string) (*MFAChallengeResponse, error) {
+	now := time.Now()
+	expiresAt := now.Add(mfaChallengeExp)
+	jti := uuid.New().String()
+	if err := app.store.MFA.CreateChallenge(ctx, jti, user.ID, expiresAt); err != nil {
+		return nil, err
+	}
+	claims := jwt.MapClaims{
+		"sub":    user.ID,
+		"iss":    app.config.auth.token.iss,
+		"aud":    app.config.auth.token.iss,
+		"exp":    expiresAt.Unix(),
+		"iat":    now.Unix(),
+		"nbf":    now.Unix(),
+		"jti":    jti,
+		"typ":    mfaChallengeType,
+		"device": device,
+	}
+
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &MFAChallengeResponse{MFARequired: true, ChallengeToken: token, ExpiresAt: expiresAt.Truncate(time.Second)}, nil
+}
+
+// checkSecondFactor verifies a TOTP code, refusing replays, or spends a
+// recovery code. It returns errInvalidSecondFactor when neither is valid and a
+// *secondFactorLockedError after too many failed attempts.
+func (app *application) checkSecondFactor(ctx context.Context, userTOTP *store.TOTP, payload SecondFactorPayload, now time.Time) error {
+	lockedUntil, err := app.store.MFA.RecordAttempt(ctx, userTOTP.UserID, mfaMaxAttempts, mfaLockout)
+	if err != nil {
+		return err
+	}
+	if lockedUntil != nil {
+		return &secondFactorLockedError{until: *lockedUntil}
+	}
+	if err := app.verifySecondFactor(ctx, userTOTP, payload, now); err != nil {
+		if err == errInvalidSecondFactor {
+			app.logger.Warnw("invalid second factor", "user", userTOTP.UserID)
+		}
+		return err
+	}
+	return app.store.MFA.ResetAttempts(ctx, userTOTP.UserID)
+}
+
+func (app *application) verifySecondFactor(ctx context.Context, userTOTP *store.TOTP, payload SecondFactorPayload, now time.Time) error {
+	if payload.Code != "" {
+		step, ok := totp.Verify(userTOTP.Secret, payload.Code, now)
+		if !ok {
+			return errInvalidSecondFactor
+		}
+		fresh, err := app.store.MFA.UseTOTPStep(ctx, userTOTP.UserID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return errInvalidSecondFactor
+		}
+		return nil
+	}
+
+	used, err := app.store.MFA.UseRecoveryCode(ctx, userTOTP.UserID, hashToken(normalizeRecoveryCode(payload.RecoveryCode)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return errInvalidSecondFactor
+	}
+	return nil
+}
+
+// newRecoveryCodes returns recovery codes formatted for the user, like
+// "abcde-fghij", and the hashes they are stored under.
+func newRecoveryCodes() ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, recoveryCodes)
+	hashes := make([]string, 0, recoveryCodes)
+	for len(codes) < recoveryCodes {
+		random := make([]byte, recoveryCodeSize*5/8)
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(random))
+		codes = append(codes, code[:recoveryCodeSize/2]+"-"+code[recoveryCodeSize/2:])
+		hashes = append(hashes, hashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode undoes the formatting users may add or drop when
+// typing a recovery code.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// getMFAStatusHandler godoc
+//
+//	@Summary		Get two-factor authentication status
+//	@Description	Whether the authenticated user has TOTP two-factor authentication enabled and how many recovery codes are left
+//	@Tags			mfa
+//	@Produce		json
+//	@Success		200	{object}	MFAStatusResponse
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/mfa [get]
+func (app *application) getMFAStatusHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	status := MFAStatusResponse{}
+	userTOTP, err := app.store.MFA.GetTOTP(ctx, user.ID)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if userTOTP != nil && userTOTP.Enabled() {
+		status.Enabled = true
+		if status.RemainingRecoveryCodes, err = app.store.MFA.RemainingRecoveryCodes(ctx, user.ID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, status); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// enrollTOTPHandler godoc
+//
+//	@Summary		Start TOTP enrollment
+//	@Description	Generate a TOTP secret for the authenticated user and the otpauth URI to add it to an authenticator app. It takes effect once a code is verified.
+//	@Tags			mfa
+//	@Produce		json
+//	@Success		201	{object}	EnrollTOTPResponse
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/mfa/totp [post]
+func (app *application) enrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.MFA.SetPendingTOTP(r.Context(), user.ID, secret); err != nil {
+		switch err {
+		case store.ErrTOTPEnabled:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	response := EnrollTOTPResponse{Secret: secret, URI: totp.URI(secret, mfaIssuer, user.Email)}
+	if err := app.jsonResponse(w, http.StatusCreated, response); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("totp enrollment started", "user", user.ID)
+}
+
+// verifyTOTPHandler godoc
+//
+//	@Summary		Verify TOTP enrollment
+//	@Description	Enable two-factor authentication with a code from the authenticator app. Returns ten one-time recovery codes, which are only shown this once.
+//	@Tags			mfa
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		VerifyTOTPPayload	true	"Code from the authenticator app"
+//	@Success		200		{object}	RecoveryCodesResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/mfa/totp/verify [post]
+func (app *application) verifyTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var payload VerifyTOTPPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	userTOTP, err := app.store.MFA.GetTOTP(ctx, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, errors.New("no totp enrollment in progress"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if userTOTP.Enabled() {
+		app.conflictResponse(w, r, store.ErrTOTPEnabled)
+		return
+	}
+
+	step, ok := totp.Verify(userTOTP.Secret, payload.Code, time.Now())
+	if !ok {
+		app.badRequestResponse(w, r, errInvalidSecondFactor)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.store.MFA.EnableTOTP(ctx, user.ID, step, hashes); err != nil {
+		switch err {
+		case store.ErrTOTPEnabled:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("totp enabled", "user", user.ID)
+}
+
+// disableTOTPHandler godoc
+//
+//	@Summary		Disable two-factor authentication
+//	@Description	Turn off TOTP two-factor authentication for the authenticated user. Requires a current code or a recovery code.
+//	@Tags			mfa
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		SecondFactorPayload	true	"Code from the authenticator app or a recovery code"
+//	@Success		204		{object}	nil
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/mfa/totp [delete]
+func (app *application) disableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var payload SecondFactorPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	userTOTP, err := app.store.MFA.GetTOTP(ctx, user.ID)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if userTOTP == nil || !userTOTP.Enabled() {
+		app.notFoundResponse(w, r, errors.New("two-factor authentication is not enabled"))
+		return
+	}
+
+	if err := app.checkSecondFactor(ctx, userTOTP, payload, time.Now()); err != nil {
+		var locked *secondFactorLockedError
+		switch {
+		case errors.As(err, &locked):
+			app.secondFactorLockedResponse(w, r, locked)
+		case err == errInvalidSecondFactor:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.MFA.DisableTOTP(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("totp disabled", "user", user.ID)
+}
+
+// mfaLoginHandler godoc
+//
+//	@Summary		Complete a two-factor login
+//	@Description	Exchange the challenge token of a login and a code from the authenticator app, or a recovery code, for an access token and a refresh token. A challenge token is good for one try; five failed tries lock the second factor for 15 minutes.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MFALoginPayload	true	"Challenge token and second factor"
+//	@Success		200		{object}	LoginUserResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/auth/mfa [post]
+func (app *application) mfaLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MFALoginPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	jwtToken, err := app.authenticator.ValidateToken(payload.ChallengeToken)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+	claims, ok := jwtToken.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != mfaChallengeType {
+		app.unauthorizedErrorResponse(w, r, errors.New("invalid challenge token"))
+		return
+	}
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, errors.New("invalid user ID in token"))
+		return
+	}
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		app.unauthorizedErrorResponse(w, r, errors.New("invalid challenge token"))
+		return
+	}
+
+	// every challenge is good for one try, a wrong code means logging in again
+	ctx := r.Context()
+	fresh, err := app.store.MFA.UseChallenge(ctx, jti, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !fresh {
+		app.unauthorizedErrorResponse(w, r, errors.New("challenge token already used or expired"))
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, errors.New("user not found"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	userTOTP, err := app.store.MFA.GetTOTP(ctx, user.ID)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if userTOTP == nil || !userTOTP.Enabled() {
+		app.unauthorizedErrorResponse(w, r, errors.New("two-factor authentication is not enabled"))
+		return
+	}
+
+	if err := app.checkSecondFactor(ctx, userTOTP, payload.SecondFactorPayload, time.Now()); err != nil {
+		var locked *secondFactorLockedError
+		switch {
+		case errors.As(err, &locked):
+			app.secondFactorLockedResponse(w, r, locked)
+		case err == errInvalidSecondFactor:
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	device, _ := claims["device"].(string)
+	response, err := app.startSession(r, user, device)
+	if err != nil {
+		app.logger.Errorw("failed to start session", "error", err)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("user logged in", "user", user.ID, "email", user.Email, "mfa", true)
+}
@@ -18,6 +18,30 @@ const userCtx userKey = "user"
 type transactionKey string
 const transactionCtx transactionKey = "transaction"
 
+type groupKey string
+const groupCtx groupKey = "group"
+
+type groupTransactionKey string
+const groupTransactionCtx groupTransactionKey = "groupTransaction"
+
+type categoryKey string
+const categoryCtx categoryKey = "category"
+
+type recurringTransactionKey string
+const recurringTransactionCtx recurringTransactionKey = "recurringTransaction"
+
+type budgetKey string
+const budgetCtx budgetKey = "budget"
+
+type goalKey string
+const goalCtx goalKey = "goal"
+
+type sessionKey string
+const sessionCtx sessionKey = "session"
+
+type scopeKey string
+const scopeCtx scopeKey = "scope"
+
 func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		token := r.Header.Get("Authorization")
@@ -31,6 +55,10 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 			return
 		}
 		token = parts[1]
+		if strings.HasPrefix(token, personalAccessTokenPrefix) {
+			app.authenticatePersonalAccessToken(w, r, next, token)
+			return
+		}
 		jwtToken, err := app.authenticator.ValidateToken(token)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
@@ -48,6 +76,11 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 			app.unauthorizedErrorResponse(w, r, errors.New("invalid user ID in token"))
 			return
 		}
+		sessionID, _ := claims["jti"].(string)
+		if sessionID == "" {
+			app.unauthorizedErrorResponse(w, r, errors.New("missing session in token"))
+			return
+		}
 		ctx := r.Context()
 		user, err := app.store.Users.GetByID(ctx, userID)
 		if err != nil {
@@ -58,7 +91,17 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 			app.internalServerError(w, r, err)
 			return
 		}
+		active, err := app.store.Sessions.IsActive(ctx, sessionID, user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !active {
+			app.unauthorizedErrorResponse(w, r, errors.New("session has been revoked"))
+			return
+		}
 		ctx = context.WithValue(r.Context(), userCtx, user)
+		ctx = context.WithValue(ctx, sessionCtx, sessionID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -76,6 +119,96 @@ func (app *application) checkTransactionOwnership(next http.HandlerFunc) http.Ha
 	})
 }
 
+func (app *application) checkGroupMembership(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		group := getGroupFromContext(r)
+		user := getUserFromContext(r)
+		for _, member := range group.Members {
+			if member.UserID == user.ID {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		app.unauthorizedErrorResponse(w, r, errors.New("user is not a member of this group"))
+	})
+}
+
+func (app *application) checkGroupOwnership(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		group := getGroupFromContext(r)
+		user := getUserFromContext(r)
+		if group.CreatedBy != user.ID {
+			app.unauthorizedErrorResponse(w, r, errors.New("user does not own this group"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) checkCategoryOwnership(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		category := getCategoryFromContext(r)
+		user := getUserFromContext(r)
+		if category.OwnerID == nil || *category.OwnerID != user.ID {
+			app.unauthorizedErrorResponse(w, r, errors.New("user does not own this category"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) checkRecurringTransactionOwnership(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recurring := getRecurringTransactionFromContext(r)
+		user := getUserFromContext(r)
+		if recurring.UserID != user.ID {
+			app.unauthorizedErrorResponse(w, r, errors.New("user does not own this recurring transaction"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) checkBudgetOwnership(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		budget := getBudgetFromContext(r)
+		user := getUserFromContext(r)
+		if budget.UserID != user.ID {
+			app.unauthorizedErrorResponse(w, r, errors.New("user does not own this budget"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) checkGoalOwnership(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		goal := getGoalFromContext(r)
+		user := getUserFromContext(r)
+		if goal.UserID != user.ID {
+			app.unauthorizedErrorResponse(w, r, errors.New("user does not own this goal"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireRole lets through users whose role has at least minLevel, see the
+// store.RoleLevel constants. It runs after AuthTokenMiddleware and reads the
+// role from the stored user, so a changed role applies to existing tokens.
+func (app *application) requireRole(minLevel int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := getUserFromContext(r)
+			if user == nil || user.Role.Level < minLevel {
+				app.forbiddenResponse(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (app *application) rateLimitMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if app.config.rateLimiter.Enabled {
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+// personalAccessTokenPrefix tells personal access tokens apart from JWTs in the
+// Authorization header.
+const personalAccessTokenPrefix = "fin_pat_"
+
+// tokenResources are the route groups personal access tokens can be scoped
+// to. Each has a ":read" scope for GET requests and a ":write" scope for the
+// rest, except analytics, which is read only.
+var tokenResources = []string{
+	"transactions",
+	"categories",
+	"budgets",
+	"goals",
+	"recurring",
+	"groups",
+	"analytics",
+}
+
+func validTokenScope(scope string) bool {
+	for _, resource := range tokenResources {
+		if scope == resource+":read" || (scope == resource+":write" && resource != "analytics") {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenScope lets personal access tokens reach the routes it wraps, provided
+// they hold the resource's read scope for GET requests or its write scope for
+// the others. Routes without it refuse personal access tokens. It has to run
+// before AuthTokenMiddleware, which enforces the scope.
+func (app *application) tokenScope(resource string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scope := resource + ":write"
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				scope = resource + ":read"
+			}
+			ctx := context.WithValue(r.Context(), scopeCtx, scope)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// authenticatePersonalAccessToken is the part of AuthTokenMiddleware that
+// handles personal access tokens.
+func (app *application) authenticatePersonalAccessToken(w http.ResponseWriter, r *http.Request, next http.Handler, plainToken string) {
+	ctx := r.Context()
+	scope, _ := ctx.Value(scopeCtx).(string)
+	if scope == "" {
+		app.logger.Warnw("personal access token used on a route without scopes", "path", r.URL.Path)
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	token, err := app.store.PersonalAccessTokens.Use(ctx, hashToken(plainToken))
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, errors.New("invalid or expired personal access token"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if !slices.Contains(token.Scopes, scope) {
+		app.logger.Warnw("personal access token lacks scope", "token", token.ID, "scope", scope)
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, token.UserID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, errors.New("user not found"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	ctx = context.WithValue(ctx, userCtx, user)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+type CreatePersonalAccessTokenPayload struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,required"`
+	ExpiresInDays int      `json:"expiresInDays" validate:"required,min=1,max=365"`
+}
+
+// PersonalAccessTokenResponse carries the token itself, which is only shown
+// when it is created.
+type PersonalAccessTokenResponse struct {
+	store.PersonalAccessToken
+	Token string `json:"token"`
+}
+
+// createPersonalAccessTokenHandler godoc
+//
+//	@Summary		Create a personal access token
+//	@Description	Create an API token for scripts and integrations, sent as "Authorization: Bearer <token>". Scopes are <resource>:read or <resource>:write for transactions, categories, budgets, goals, recurring and groups, and analytics:read. The token is only returned this once.
+//	@Tags			tokens
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreatePersonalAccessTokenPayload	true	"Token name, scopes and lifetime"
+//	@Success		201		{object}	PersonalAccessTokenResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tokens [post]
+func (app *application) createPersonalAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreatePersonalAccessTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	for _, scope := range payload.Scopes {
+		if !validTokenScope(scope) {
+			app.badRequestResponse(w, r, fmt.Errorf("unknown scope: %s", scope))
+			return
+		}
+	}
+	slices.Sort(payload.Scopes)
+
+	random := make([]byte, 20)
+	if _, err := rand.Read(random); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	plainToken := personalAccessTokenPrefix + hex.EncodeToString(random)
+
+	user := getUserFromContext(r)
+	token := &store.PersonalAccessToken{
+		UserID:    user.ID,
+		Name:      payload.Name,
+		Scopes:    slices.Compact(payload.Scopes),
+		TokenHash: hashToken(plainToken),
+		ExpiresAt: time.Now().AddDate(0, 0, payload.ExpiresInDays).Truncate(time.Second),
+	}
+	if err := app.store.PersonalAccessTokens.Create(r.Context(), token); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := PersonalAccessTokenResponse{PersonalAccessToken: *token, Token: plainToken}
+	if err := app.jsonResponse(w, http.StatusCreated, response); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("personal access token created", "user", user.ID, "token", token.ID, "scopes", token.Scopes)
+}
+
+// listPersonalAccessTokensHandler godoc
+//
+//	@Summary		List personal access tokens
+//	@Description	List the authenticated user's personal access tokens, without the tokens themselves
+//	@Tags			tokens
+//	@Produce		json
+//	@Success		200	{object}	[]store.PersonalAccessToken
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tokens [get]
+func (app *application) listPersonalAccessTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	tokens, err := app.store.PersonalAccessTokens.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// deletePersonalAccessTokenHandler godoc
+//
+//	@Summary		Revoke a personal access token
+//	@Description	Delete one of the authenticated user's personal access tokens. It stops working immediately.
+//	@Tags			tokens
+//	@Produce		json
+//	@Param			id	path		int	true	"Token ID"
+//	@Success		204	{object}	nil
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tokens/{id} [delete]
+func (app *application) deletePersonalAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	if err := app.store.PersonalAccessTokens.Delete(r.Context(), user.ID, id); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("personal access token revoked", "user", user.ID, "token", id)
+}
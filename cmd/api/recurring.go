@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+type CreateRecurringTransactionRequest struct {
+	Amount          float64 `json:"amount" validate:"required,gt=0"`
+	TransactionType string  `json:"transactionType" validate:"required,oneof=income expense"`
+	Description     string  `json:"description" validate:"max=255"`
+	CategoryName    string  `json:"categoryName" validate:"required"`
+	Frequency       string  `json:"frequency" validate:"required,oneof=daily weekly monthly yearly"`
+	// Interval repeats every n periods, e.g. 2 with a weekly frequency is every other week.
+	Interval  int    `json:"interval" validate:"omitempty,gte=1,lte=365"`
+	StartDate string `json:"startDate" validate:"required"` // RFC3339
+	EndDate   string `json:"endDate"`                       // RFC3339, optional
+	Count     *int   `json:"count" validate:"omitempty,gte=1"`
+}
+
+// createRecurringTransactionHandler godoc
+//
+//	@Summary		Create a recurring transaction
+//	@Description	Create a recurring transaction template. Due occurrences are posted as transactions by the scheduler.
+//	@Tags			recurring-transactions
+//	@Accept			json
+//	@Produce		json
+//	@Param			recurring	body		CreateRecurringTransactionRequest	true	"Recurring transaction data"
+//	@Success		201			{object}	store.RecurringTransaction
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/recurring-transactions [post]
+func (app *application) createRecurringTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateRecurringTransactionRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	startDate, err := time.Parse(time.RFC3339, payload.StartDate)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	endDate, err := parseOptionalDate(payload.EndDate)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if endDate != nil && endDate.Before(startDate) {
+		app.badRequestResponse(w, r, errors.New("endDate must not be before startDate"))
+		return
+	}
+	if payload.Interval == 0 {
+		payload.Interval = 1
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	category, err := app.store.Category.GetByName(ctx, user.ID, payload.CategoryName)
+	if err != nil {
+		if err == store.ErrNotFound {
+			app.badRequestResponse(w, r, fmt.Errorf("category not found"))
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	recurring := &store.RecurringTransaction{
+		UserID:          user.ID,
+		CategoryID:      category.ID,
+		CategoryName:    category.Name,
+		Amount:          payload.Amount,
+		TransactionType: payload.TransactionType,
+		Description:     payload.Description,
+		Frequency:       payload.Frequency,
+		Interval:        payload.Interval,
+		StartDate:       startDate,
+		EndDate:         endDate,
+		OccurrenceLimit: payload.Count,
+	}
+	if err := app.store.RecurringTransactions.Create(ctx, recurring); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, recurring); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("recurring transaction created", "recurring", recurring.ID, "user", user.ID)
+}
+
+// listRecurringTransactionsHandler godoc
+//
+//	@Summary		List recurring transactions
+//	@Description	List the authenticated user's recurring transaction templates
+//	@Tags			recurring-transactions
+//	@Produce		json
+//	@Success		200	{object}	[]store.RecurringTransaction
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/recurring-transactions [get]
+func (app *application) listRecurringTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	recurring, err := app.store.RecurringTransactions.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, recurring); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getRecurringTransactionHandler godoc
+//
+//	@Summary		Get a recurring transaction
+//	@Description	Get a recurring transaction template by ID
+//	@Tags			recurring-transactions
+//	@Produce		json
+//	@Param			id	path		int	true	"Recurring transaction ID"
+//	@Success		200	{object}	store.RecurringTransaction
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/recurring-transactions/{id} [get]
+func (app *application) getRecurringTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, getRecurringTransactionFromContext(r)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateRecurringTransactionRequest changes what is posted from now on. The
+// schedule (frequency, interval and start date) is fixed; to change it, delete
+// the template and create a new one.
+type UpdateRecurringTransactionRequest struct {
+	Amount          *float64 `json:"amount" validate:"omitempty,gt=0"`
+	TransactionType *string  `json:"transactionType" validate:"omitempty,oneof=income expense"`
+	Description     *string  `json:"description" validate:"omitempty,max=255"`
+	CategoryName    *string  `json:"categoryName" validate:"omitempty,min=1"`
+	// EndDate and Count clear the limit when set to "" and 0.
+	EndDate *string `json:"endDate"`
+	Count   *int    `json:"count" validate:"omitempty,gte=0"`
+}
+
+// updateRecurringTransactionHandler godoc
+//
+//	@Summary		Update a recurring transaction
+//	@Description	Update the amount, type, description, category, end date or count of a recurring transaction. Already posted transactions are not changed.
+//	@Tags			recurring-transactions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int									true	"Recurring transaction ID"
+//	@Param			recurring	body		UpdateRecurringTransactionRequest	true	"Recurring transaction data"
+//	@Success		200			{object}	store.RecurringTransaction
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/recurring-transactions/{id} [patch]
+func (app *application) updateRecurringTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateRecurringTransactionRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	recurring := getRecurringTransactionFromContext(r)
+
+	if payload.Amount != nil {
+		recurring.Amount = *payload.Amount
+	}
+	if payload.TransactionType != nil {
+		recurring.TransactionType = *payload.TransactionType
+	}
+	if payload.Description != nil {
+		recurring.Description = *payload.Description
+	}
+	if payload.CategoryName != nil {
+		category, err := app.store.Category.GetByName(ctx, user.ID, *payload.CategoryName)
+		if err != nil {
+			if err == store.ErrNotFound {
+				app.badRequestResponse(w, r, fmt.Errorf("category not found"))
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+		recurring.CategoryID = category.ID
+		recurring.CategoryName = category.Name
+	}
+	if payload.EndDate != nil {
+		endDate, err := parseOptionalDate(*payload.EndDate)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		if endDate != nil && endDate.Before(recurring.StartDate) {
+			app.badRequestResponse(w, r, errors.New("endDate must not be before startDate"))
+			return
+		}
+		recurring.EndDate = endDate
+	}
+	if payload.Count != nil {
+		if *payload.Count == 0 {
+			recurring.OccurrenceLimit = nil
+		} else {
+			recurring.OccurrenceLimit = payload.Count
+		}
+	}
+
+	if err := app.store.RecurringTransactions.Update(ctx, recurring); err != nil {
+		if err == store.ErrNotFound {
+			app.notFoundResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, recurring); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("recurring transaction updated", "recurring", recurring.ID)
+}
+
+// deleteRecurringTransactionHandler godoc
+//
+//	@Summary		Delete a recurring transaction
+//	@Description	Stop and delete a recurring transaction. Transactions it already posted are kept.
+//	@Tags			recurring-transactions
+//	@Produce		json
+//	@Param			id	path		int	true	"Recurring transaction ID"
+//	@Success		204	{object}	nil
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/recurring-transactions/{id} [delete]
+func (app *application) deleteRecurringTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	recurring := getRecurringTransactionFromContext(r)
+	if err := app.store.RecurringTransactions.Delete(r.Context(), recurring.ID); err != nil {
+		if err == store.ErrNotFound {
+			app.notFoundResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("recurring transaction deleted", "recurring", recurring.ID)
+}
+
+// parseOptionalDate parses an RFC3339 date, returning nil for an empty string.
+func parseOptionalDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (app *application) recurringTransactionContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recurringID := chi.URLParam(r, "id")
+		recurringIDInt, err := strconv.ParseInt(recurringID, 10, 64)
+		if err != nil || recurringIDInt <= 0 {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid recurring transaction ID: %s", recurringID))
+			return
+		}
+
+		ctx := r.Context()
+		recurring, err := app.store.RecurringTransactions.GetByID(ctx, recurringIDInt)
+		if err != nil {
+			if err == store.ErrNotFound {
+				app.notFoundResponse(w, r, err)
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+		ctx = context.WithValue(r.Context(), recurringTransactionCtx, recurring)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getRecurringTransactionFromContext(r *http.Request) *store.RecurringTransaction {
+	recurring, _ := r.Context().Value(recurringTransactionCtx).(*store.RecurringTransaction)
+	return recurring
+}
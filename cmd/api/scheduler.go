@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// recurringBatchSize is how many recurring templates are processed per transaction.
+const recurringBatchSize = 100
+
+// runRecurringScheduler posts due recurring transactions every interval until
+// ctx is cancelled. Posting is idempotent, so it is safe to run on every
+// instance and to restart at any time. A zero interval disables the scheduler.
+func (app *application) runRecurringScheduler(ctx context.Context) {
+	if app.config.scheduler.interval <= 0 {
+		app.logger.Infow("recurring transaction scheduler is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(app.config.scheduler.interval)
+	defer ticker.Stop()
+
+	app.logger.Infow("recurring transaction scheduler has started", "interval", app.config.scheduler.interval.String())
+	for {
+		app.postDueRecurringTransactions(ctx)
+
+		select {
+		case <-ctx.Done():
+			app.logger.Infow("recurring transaction scheduler has stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *application) postDueRecurringTransactions(ctx context.Context) {
+	for {
+		posted, err := app.store.RecurringTransactions.PostDue(ctx, time.Now(), recurringBatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				app.logger.Errorw("failed to post recurring transactions", "error", err)
+			}
+			return
+		}
+		if len(posted) == 0 {
+			return
+		}
+		app.logger.Infow("recurring transactions posted", "count", len(posted))
+		app.flagAnomalies(posted)
+	}
+}
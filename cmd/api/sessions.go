@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+type SessionResponse struct {
+	store.Session
+	// Current marks the session the request was made with.
+	Current bool `json:"current"`
+}
+
+type RevokeSessionsResponse struct {
+	Revoked int64 `json:"revoked"`
+}
+
+func getSessionIDFromContext(r *http.Request) string {
+	sessionID, _ := r.Context().Value(sessionCtx).(string)
+	return sessionID
+}
+
+// listSessionsHandler godoc
+//
+//	@Summary		List sessions
+//	@Description	List the authenticated user's active sessions, most recently used first
+//	@Tags			sessions
+//	@Produce		json
+//	@Success		200	{object}	[]SessionResponse
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/sessions [get]
+func (app *application) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	sessions, err := app.store.Sessions.ListActive(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	currentID := getSessionIDFromContext(r)
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{Session: session, Current: session.ID == currentID})
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// logoutHandler godoc
+//
+//	@Summary		Log out
+//	@Description	End the session the request was made with. Its access and refresh tokens stop working.
+//	@Tags			sessions
+//	@Produce		json
+//	@Success		204	{object}	nil
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/sessions/current [delete]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	if err := app.store.Sessions.Revoke(r.Context(), user.ID, getSessionIDFromContext(r)); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, errors.New("session has been revoked"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("user logged out", "user", user.ID)
+}
+
+// logoutOtherSessionsHandler godoc
+//
+//	@Summary		Log out other sessions
+//	@Description	End every session of the authenticated user except the one the request was made with
+//	@Tags			sessions
+//	@Produce		json
+//	@Success		200	{object}	RevokeSessionsResponse
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/sessions/others [delete]
+func (app *application) logoutOtherSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	revoked, err := app.store.Sessions.RevokeOthers(r.Context(), user.ID, getSessionIDFromContext(r))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, RevokeSessionsResponse{Revoked: revoked}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("other sessions logged out", "user", user.ID, "revoked", revoked)
+}
+
+// revokeSessionHandler godoc
+//
+//	@Summary		Revoke a session
+//	@Description	End one of the authenticated user's sessions, e.g. a device that was lost
+//	@Tags			sessions
+//	@Produce		json
+//	@Param			id	path		string	true	"Session ID"
+//	@Success		204	{object}	nil
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/sessions/{id} [delete]
+func (app *application) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	user := getUserFromContext(r)
+	if err := app.store.Sessions.Revoke(r.Context(), user.ID, id); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("session revoked", "user", user.ID, "session", id)
+}
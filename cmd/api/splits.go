@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/sumit8974/finance-tracker/internal/store"
+)
+
+type SplitRequest struct {
+	Type    string               `json:"type" validate:"required,oneof=equal exact percentage shares"`
+	Members []SplitMemberRequest `json:"members" validate:"omitempty,dive"`
+}
+
+// SplitMemberRequest holds the member's amount for exact splits, their percentage
+// for percentage splits and their share count for share splits. Value is ignored
+// for equal splits.
+type SplitMemberRequest struct {
+	UserID int64   `json:"userId" validate:"required,gt=0"`
+	Value  float64 `json:"value" validate:"gte=0"`
+}
+
+// computeSplits turns a split request into per-member amounts that add up to the
+// transaction amount to the cent. Equal splits without members are shared by every
+// member of the group.
+func computeSplits(amount float64, req SplitRequest, group *store.Group) ([]store.GroupTransactionSplit, error) {
+	usernames := make(map[int64]string, len(group.Members))
+	for _, member := range group.Members {
+		usernames[member.UserID] = member.Username
+	}
+
+	members := req.Members
+	if req.Type == store.SplitEqual && len(members) == 0 {
+		for _, member := range group.Members {
+			members = append(members, SplitMemberRequest{UserID: member.UserID})
+		}
+	}
+	if len(members) == 0 {
+		return nil, errors.New("split must include at least one member")
+	}
+
+	seen := make(map[int64]bool, len(members))
+	for _, member := range members {
+		if _, ok := usernames[member.UserID]; !ok {
+			return nil, fmt.Errorf("user %d is not a member of this group", member.UserID)
+		}
+		if seen[member.UserID] {
+			return nil, fmt.Errorf("user %d appears more than once in the split", member.UserID)
+		}
+		seen[member.UserID] = true
+	}
+
+	total := toCents(amount)
+	var cents []int64
+	switch req.Type {
+	case store.SplitEqual:
+		weights := make([]float64, len(members))
+		for i := range weights {
+			weights[i] = 1
+		}
+		cents = allocateByWeight(total, weights)
+	case store.SplitExact:
+		var sum int64
+		cents = make([]int64, len(members))
+		for i, member := range members {
+			cents[i] = toCents(member.Value)
+			sum += cents[i]
+		}
+		if sum != total {
+			return nil, fmt.Errorf("split amounts add up to %.2f but the transaction amount is %.2f", fromCents(sum), fromCents(total))
+		}
+	case store.SplitPercentage:
+		var sum int64
+		weights := make([]float64, len(members))
+		for i, member := range members {
+			weights[i] = member.Value
+			sum += toCents(member.Value)
+		}
+		if sum != 100*100 {
+			return nil, fmt.Errorf("split percentages add up to %.2f%% instead of 100%%", fromCents(sum))
+		}
+		cents = allocateByWeight(total, weights)
+	case store.SplitShares:
+		weights := make([]float64, len(members))
+		var sum float64
+		for i, member := range members {
+			weights[i] = member.Value
+			sum += member.Value
+		}
+		if sum <= 0 {
+			return nil, errors.New("split shares must add up to more than zero")
+		}
+		cents = allocateByWeight(total, weights)
+	default:
+		return nil, fmt.Errorf("invalid split type: %s", req.Type)
+	}
+
+	splits := make([]store.GroupTransactionSplit, len(members))
+	for i, member := range members {
+		splits[i] = store.GroupTransactionSplit{
+			UserID:   member.UserID,
+			Username: usernames[member.UserID],
+			Amount:   fromCents(cents[i]),
+		}
+		if req.Type == store.SplitPercentage || req.Type == store.SplitShares {
+			share := member.Value
+			splits[i].Share = &share
+		}
+	}
+	return splits, nil
+}
+
+// splitRequestFromTransaction rebuilds the split request a stored transaction was
+// created with, so an update that doesn't send a split keeps the existing one.
+func splitRequestFromTransaction(transaction *store.GroupTransaction) SplitRequest {
+	req := SplitRequest{Type: transaction.SplitType}
+	if req.Type == "" || len(transaction.Splits) == 0 {
+		return SplitRequest{Type: store.SplitEqual}
+	}
+	for _, split := range transaction.Splits {
+		member := SplitMemberRequest{UserID: split.UserID, Value: split.Amount}
+		if split.Share != nil {
+			member.Value = *split.Share
+		}
+		req.Members = append(req.Members, member)
+	}
+	return req
+}
+
+// withSplitParticipants returns group with the participants of the
+// transaction's stored splits added as members. Keeping an existing split then
+// still works after some of its participants have left the group.
+func withSplitParticipants(group *store.Group, transaction *store.GroupTransaction) *store.Group {
+	members := make(map[int64]bool, len(group.Members))
+	for _, member := range group.Members {
+		members[member.UserID] = true
+	}
+
+	extended := *group
+	extended.Members = append([]store.GroupMember(nil), group.Members...)
+	for _, split := range transaction.Splits {
+		if !members[split.UserID] {
+			extended.Members = append(extended.Members, store.GroupMember{UserID: split.UserID, Username: split.Username})
+			members[split.UserID] = true
+		}
+	}
+	return &extended
+}
+
+// allocateByWeight distributes total cents proportionally to weights using the
+// largest remainder method, so the parts always add up to total.
+func allocateByWeight(total int64, weights []float64) []int64 {
+	var sum float64
+	for _, w := range weights {
+		sum += w
+	}
+
+	parts := make([]int64, len(weights))
+	if sum == 0 {
+		return parts
+	}
+
+	remainders := make([]float64, len(weights))
+	var allocated int64
+	for i, w := range weights {
+		exact := float64(total) * w / sum
+		parts[i] = int64(math.Floor(exact))
+		remainders[i] = exact - float64(parts[i])
+		allocated += parts[i]
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for i := 0; allocated < total; i++ {
+		parts[order[i%len(order)]]++
+		allocated++
+	}
+	return parts
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -55,7 +56,7 @@ func (app *application) createTransactionHandler(w http.ResponseWriter, r *http.
 	payload.TransactionDate = parsedTime.Format(time.RFC3339) // Convert to date-only format
 	// TODO: Try to get the category id using the category name from the database
 	ctx := r.Context()
-	categoryDetails, err := app.store.Category.GetByName(ctx, payload.CategoryName)
+	categoryDetails, err := app.store.Category.GetByName(ctx, user.ID, payload.CategoryName)
 	if categoryDetails == nil {
 		app.badRequestResponse(w, r, fmt.Errorf("category not found"))
 		return
@@ -84,12 +85,78 @@ func (app *application) createTransactionHandler(w http.ResponseWriter, r *http.
 		app.internalServerError(w, r, err)
 		return
 	}
+	app.flagAnomaly(ctx, transactionData)
 
 	err = app.jsonResponse(w, http.StatusCreated, transactionData)
 	if err != nil {
 		app.internalServerError(w, r, err)
 	}
 	app.logger.Infof("Transaction created: %v", transactionData)
+	app.checkBudgetAlerts(user, transactionData)
+}
+
+// parseTransactionFilter reads the transaction list filters from the query string.
+func parseTransactionFilter(r *http.Request) (store.ListTransactionsByUserFilter, error) {
+	var filter store.ListTransactionsByUserFilter
+	queryParams := r.URL.Query()
+	if startDate := queryParams.Get("startDate"); startDate != "" {
+		if _, err := time.Parse(time.DateOnly, startDate); err != nil {
+			return filter, err
+		}
+		filter.StartDate = startDate
+	}
+	if endDate := queryParams.Get("endDate"); endDate != "" {
+		if _, err := time.Parse(time.DateOnly, endDate); err != nil {
+			return filter, err
+		}
+		filter.EndDate = endDate
+	}
+	transactionType := queryParams.Get("transactionType")
+	if transactionType != "" {
+		if transactionType != "income" && transactionType != "expense" {
+			return filter, fmt.Errorf("invalid transaction type: %s", transactionType)
+		}
+		filter.TransactionType = transactionType
+	}
+	// categoryIds can be repeated and/or comma separated
+	for _, value := range queryParams["categoryIds"] {
+		for _, id := range strings.Split(value, ",") {
+			if id = strings.TrimSpace(id); id == "" {
+				continue
+			}
+			categoryID, err := strconv.ParseInt(id, 10, 64)
+			if err != nil {
+				return filter, fmt.Errorf("invalid category ID: %s", id)
+			}
+			filter.CategoryIDs = append(filter.CategoryIDs, categoryID)
+		}
+	}
+	if minAmount := queryParams.Get("minAmount"); minAmount != "" {
+		amount, err := strconv.ParseFloat(minAmount, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid minAmount: %s", minAmount)
+		}
+		filter.MinAmount = &amount
+	}
+	if maxAmount := queryParams.Get("maxAmount"); maxAmount != "" {
+		amount, err := strconv.ParseFloat(maxAmount, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid maxAmount: %s", maxAmount)
+		}
+		filter.MaxAmount = &amount
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		return filter, fmt.Errorf("minAmount cannot be greater than maxAmount")
+	}
+	if filter.StartDate != "" && filter.EndDate != "" && filter.StartDate > filter.EndDate {
+		return filter, fmt.Errorf("startDate cannot be after endDate")
+	}
+	filter.Search = strings.TrimSpace(queryParams.Get("search"))
+
+	if err := Validate.Struct(filter); err != nil {
+		return filter, err
+	}
+	return filter, nil
 }
 
 // listTransactionsHandler godoc
@@ -98,41 +165,41 @@ func (app *application) createTransactionHandler(w http.ResponseWriter, r *http.
 //	@Description	List transactions for the authenticated user
 //	@Tags			transactions
 //	@Produce		json
-//	@Success		200	{array}		store.Transaction
+//	@Success		200	{object}	store.TransactionPage
+//	@Failure		400	{object}	error
 //	@Failure		401	{object}	error
 //	@Failure		500	{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/transactions [get]
-//	@Param			startDate		query	string	false	"Start date in RFC3339 format"
-//	@Param			endDate			query	string	false	"End date in RFC3339 format"
+//	@Param			startDate		query	string	false	"Start transaction date (YYYY-MM-DD, inclusive)"
+//	@Param			endDate			query	string	false	"End transaction date (YYYY-MM-DD, inclusive)"
 //	@Param			transactionType	query	string	false	"Transaction type (income/expense)"
+//	@Param			categoryIds		query	[]int	false	"Category IDs, repeated or comma separated"
+//	@Param			minAmount		query	number	false	"Minimum amount"
+//	@Param			maxAmount		query	number	false	"Maximum amount"
+//	@Param			search			query	string	false	"Case insensitive description substring"
+//	@Param			limit			query	int		false	"Page size (default 50, max 200)"
+//	@Param			cursor			query	string	false	"Cursor returned as next_cursor by the previous page"
+//	@Param			sortBy			query	string	false	"Sort by date or amount (default date)"
+//	@Param			sortOrder		query	string	false	"Sort order asc or desc (default desc)"
 func (app *application) listTransactionsHandler(w http.ResponseWriter, r *http.Request) {
-	var listTransactionsFilter store.ListTransactionsByUserFilter
-	queryParams := r.URL.Query()
-	if startDate := queryParams.Get("startDate"); startDate != "" {
-		_, err := time.Parse(time.DateOnly, startDate)
-		if err != nil {
-			app.badRequestResponse(w, r, err)
-			return
-		}
-		listTransactionsFilter.StartDate = startDate
+	listTransactionsFilter, err := parseTransactionFilter(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
-	if endDate := queryParams.Get("endDate"); endDate != "" {
-		_, err := time.Parse(time.DateOnly, endDate)
+	queryParams := r.URL.Query()
+	if limit := queryParams.Get("limit"); limit != "" {
+		limitInt, err := strconv.Atoi(limit)
 		if err != nil {
-			app.badRequestResponse(w, r, err)
-			return
-		}
-		listTransactionsFilter.EndDate = endDate
-	}
-	transactionType := queryParams.Get("transactionType")
-	if transactionType != "" {
-		if transactionType != "income" && transactionType != "expense" {
-			app.badRequestResponse(w, r, fmt.Errorf("invalid transaction type: %s", transactionType))
+			app.badRequestResponse(w, r, fmt.Errorf("invalid limit: %s", limit))
 			return
 		}
-		listTransactionsFilter.TransactionType = transactionType
+		listTransactionsFilter.Limit = limitInt
 	}
+	listTransactionsFilter.Cursor = queryParams.Get("cursor")
+	listTransactionsFilter.SortBy = queryParams.Get("sortBy")
+	listTransactionsFilter.SortOrder = queryParams.Get("sortOrder")
 
 	if err := Validate.Struct(listTransactionsFilter); err != nil {
 		app.badRequestResponse(w, r, err)
@@ -143,6 +210,10 @@ func (app *application) listTransactionsHandler(w http.ResponseWriter, r *http.R
 	ctx := r.Context()
 	transactions, err := app.store.Transactions.ListTransactionsByUser(ctx, user.ID, listTransactionsFilter)
 	if err != nil {
+		if err == store.ErrInvalidCursor {
+			app.badRequestResponse(w, r, err)
+			return
+		}
 		if err == store.ErrNotFound {
 			app.notFoundResponse(w, r, err)
 			return
@@ -155,7 +226,7 @@ func (app *application) listTransactionsHandler(w http.ResponseWriter, r *http.R
 		app.internalServerError(w, r, err)
 		return
 	}
-	app.logger.Infof("Transactions listed: %v", transactions)
+	app.logger.Infof("Transactions listed: %d", len(transactions.Transactions))
 }
 
 // getTransactionByIDHandler godoc
@@ -251,9 +322,10 @@ func (app *application) updateTransactionByIDHandler(w http.ResponseWriter, r *h
 		return
 	}
 	transaction := getTransactionFromContext(r)
+	user := getUserFromContext(r)
 
 	ctx := r.Context()
-	categoryDetails, err := app.store.Category.GetByName(ctx, payload.CategoryName)
+	categoryDetails, err := app.store.Category.GetByName(ctx, user.ID, payload.CategoryName)
 	if err != nil {
 		if err == store.ErrNotFound {
 			app.badRequestResponse(w, r, fmt.Errorf("category not found"))
@@ -279,6 +351,7 @@ func (app *application) updateTransactionByIDHandler(w http.ResponseWriter, r *h
 		return
 	}
 	transaction.CategoryName = categoryDetails.Name
+	app.flagAnomaly(ctx, transaction)
 
 	err = app.jsonResponse(w, http.StatusOK, transaction)
 	if err != nil {
@@ -286,6 +359,7 @@ func (app *application) updateTransactionByIDHandler(w http.ResponseWriter, r *h
 		return
 	}
 	app.logger.Infof("Transaction updated: %v", transaction)
+	app.checkBudgetAlerts(user, transaction)
 }
 
 func (app *application) transactionContextMiddleware(next http.Handler) http.Handler {
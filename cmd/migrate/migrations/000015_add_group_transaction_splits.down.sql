@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS group_transaction_splits;
+
+ALTER TABLE group_transactions DROP COLUMN IF EXISTS split_type;
\ No newline at end of file
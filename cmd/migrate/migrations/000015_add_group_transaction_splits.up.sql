@@ -0,0 +1,9 @@
+ALTER TABLE group_transactions ADD COLUMN split_type varchar(20) NOT NULL DEFAULT 'equal';
+
+CREATE TABLE IF NOT EXISTS group_transaction_splits (
+    transaction_id bigint NOT NULL REFERENCES group_transactions(id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount decimal(15,2) NOT NULL,
+    share decimal(15,4), -- percentage or share count the amount was derived from
+    PRIMARY KEY (transaction_id, user_id)
+);
\ No newline at end of file
@@ -0,0 +1,4 @@
+DELETE FROM group_transactions WHERE category_id IS NULL;
+
+ALTER TABLE group_transactions
+  ALTER COLUMN category_id SET NOT NULL;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- settlements are stored as group transactions without a category
+ALTER TABLE group_transactions
+  ALTER COLUMN category_id DROP DEFAULT,
+  ALTER COLUMN category_id DROP NOT NULL;
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS categories_owner_id_name_key;
+
+ALTER TABLE categories DROP COLUMN IF EXISTS owner_id;
\ No newline at end of file
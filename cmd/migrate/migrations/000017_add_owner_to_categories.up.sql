@@ -0,0 +1,4 @@
+-- categories without an owner are the system defaults visible to everyone
+ALTER TABLE categories ADD COLUMN owner_id bigint REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS categories_owner_id_name_key ON categories (owner_id, name) WHERE owner_id IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE categories ADD COLUMN parent_id bigint REFERENCES categories(id) ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS categories_parent_id_idx ON categories (parent_id);
\ No newline at end of file
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS individual_transactions_recurring_occurrence_key;
+
+ALTER TABLE individual_transactions DROP COLUMN IF EXISTS recurring_transaction_id;
+
+DROP TABLE IF EXISTS recurring_transactions;
\ No newline at end of file
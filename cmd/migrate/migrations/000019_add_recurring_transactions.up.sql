@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS recurring_transactions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    category_id bigint NOT NULL REFERENCES categories(id),
+    amount decimal(15,2) NOT NULL,
+    transaction_type varchar(10) NOT NULL, -- 'income' or 'expense'
+    description text,
+    frequency varchar(10) NOT NULL, -- 'daily', 'weekly', 'monthly' or 'yearly'
+    repeat_interval int NOT NULL DEFAULT 1,
+    start_date timestamp(0) with time zone NOT NULL,
+    end_date timestamp(0) with time zone,
+    occurrence_limit int, -- NULL repeats until end_date or forever
+    occurrences_posted int NOT NULL DEFAULT 0,
+    next_run_at timestamp(0) with time zone, -- NULL once the schedule has ended
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS recurring_transactions_next_run_at_idx ON recurring_transactions (next_run_at) WHERE next_run_at IS NOT NULL;
+
+ALTER TABLE individual_transactions ADD COLUMN recurring_transaction_id bigint REFERENCES recurring_transactions(id) ON DELETE SET NULL;
+
+-- an occurrence can only ever be posted once, even if the scheduler is restarted mid-run
+CREATE UNIQUE INDEX IF NOT EXISTS individual_transactions_recurring_occurrence_key
+  ON individual_transactions (recurring_transaction_id, transaction_date)
+  WHERE recurring_transaction_id IS NOT NULL;
\ No newline at end of file
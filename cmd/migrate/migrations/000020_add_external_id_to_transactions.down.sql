@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS individual_transactions_user_id_external_id_key;
+
+ALTER TABLE individual_transactions DROP COLUMN IF EXISTS external_id;
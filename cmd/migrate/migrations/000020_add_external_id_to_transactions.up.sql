@@ -0,0 +1,7 @@
+-- identifies transactions imported from a bank statement, e.g. by their OFX FITID,
+-- so importing the same statement twice does not duplicate them
+ALTER TABLE individual_transactions ADD COLUMN external_id text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS individual_transactions_user_id_external_id_key
+  ON individual_transactions (user_id, external_id)
+  WHERE external_id IS NOT NULL;
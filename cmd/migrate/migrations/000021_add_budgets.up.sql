@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS budgets (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    category_id bigint NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    period date NOT NULL, -- first day of the budgeted month
+    limit_amount decimal(15,2) NOT NULL CHECK (limit_amount > 0),
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    CONSTRAINT budgets_period_month_check CHECK (period = date_trunc('month', period)),
+    CONSTRAINT budgets_user_id_category_id_period_key UNIQUE (user_id, category_id, period)
+);
+
+CREATE INDEX IF NOT EXISTS budgets_user_id_period_idx ON budgets (user_id, period);
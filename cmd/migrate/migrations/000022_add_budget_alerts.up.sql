@@ -0,0 +1,8 @@
+-- thresholds (percent of the limit) already reported for a budget, so every
+-- threshold is only mailed once per budget period
+CREATE TABLE IF NOT EXISTS budget_alerts (
+    budget_id bigint NOT NULL REFERENCES budgets(id) ON DELETE CASCADE,
+    threshold int NOT NULL,
+    sent_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (budget_id, threshold)
+);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS budget_allocations;
+
+DELETE FROM budgets WHERE limit_amount = 0;
+ALTER TABLE budgets DROP CONSTRAINT IF EXISTS budgets_limit_amount_check;
+ALTER TABLE budgets ADD CONSTRAINT budgets_limit_amount_check CHECK (limit_amount > 0);
+
+ALTER TABLE budgets DROP COLUMN IF EXISTS envelope;
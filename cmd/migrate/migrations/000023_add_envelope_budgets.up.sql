@@ -0,0 +1,19 @@
+-- envelope budgets carry what is left (or overspent) into the next month
+ALTER TABLE budgets ADD COLUMN envelope boolean NOT NULL DEFAULT false;
+
+-- a budget created by a rollover starts without an allocation of its own
+ALTER TABLE budgets DROP CONSTRAINT IF EXISTS budgets_limit_amount_check;
+ALTER TABLE budgets ADD CONSTRAINT budgets_limit_amount_check CHECK (limit_amount >= 0);
+
+-- append-only ledger of the money in an envelope; its sum is what the envelope holds
+CREATE TABLE IF NOT EXISTS budget_allocations (
+    id bigserial PRIMARY KEY,
+    budget_id bigint NOT NULL REFERENCES budgets(id) ON DELETE CASCADE,
+    kind varchar(20) NOT NULL, -- 'allocation', 'rollover' or 'transfer'
+    amount decimal(15,2) NOT NULL, -- negative when money leaves the envelope
+    related_budget_id bigint REFERENCES budgets(id) ON DELETE SET NULL, -- the other side of a rollover or transfer
+    note text,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS budget_allocations_budget_id_idx ON budget_allocations (budget_id);
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS goals (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name varchar(100) NOT NULL,
+    target_amount decimal(15,2) NOT NULL CHECK (target_amount > 0),
+    target_date date NOT NULL,
+    category_id bigint REFERENCES categories(id) ON DELETE SET NULL, -- transactions of the category count towards the goal
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS goals_user_id_idx ON goals (user_id);
+
+-- a contribution is either an explicit deposit or a transaction tagged with the goal
+CREATE TABLE IF NOT EXISTS goal_contributions (
+    id bigserial PRIMARY KEY,
+    goal_id bigint NOT NULL REFERENCES goals(id) ON DELETE CASCADE,
+    transaction_id bigint REFERENCES individual_transactions(id) ON DELETE CASCADE,
+    amount decimal(15,2) CHECK (amount > 0),
+    contribution_date date,
+    note text,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    CONSTRAINT goal_contributions_source_check CHECK (
+        (transaction_id IS NOT NULL AND amount IS NULL AND contribution_date IS NULL)
+        OR (transaction_id IS NULL AND amount IS NOT NULL AND contribution_date IS NOT NULL)
+    ),
+    CONSTRAINT goal_contributions_goal_id_transaction_id_key UNIQUE (goal_id, transaction_id)
+);
@@ -0,0 +1,12 @@
+-- flags set when a transaction is created or updated, so reads never recompute them
+CREATE TABLE IF NOT EXISTS transaction_anomalies (
+    transaction_id bigint PRIMARY KEY REFERENCES individual_transactions(id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason varchar(30) NOT NULL, -- 'amount_outlier' or 'new_merchant'
+    mean decimal(15,2), -- the category's rolling mean for an outlier
+    std_dev decimal(15,2),
+    score decimal(10,2), -- standard deviations above the mean
+    detected_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS transaction_anomalies_user_id_idx ON transaction_anomalies (user_id);
@@ -0,0 +1,15 @@
+-- opaque refresh tokens, stored as sha256 hashes. Every login starts a family
+-- and every refresh rotates the token within it.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id uuid NOT NULL,
+    token_hash varchar(64) NOT NULL UNIQUE,
+    expires_at timestamp(0) with time zone NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    used_at timestamp(0) with time zone, -- set when the token was rotated
+    revoked_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_id_idx ON refresh_tokens (user_id);
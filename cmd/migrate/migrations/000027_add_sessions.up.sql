@@ -0,0 +1,26 @@
+-- one row per login. The id is the jti of its access tokens and the family of
+-- its refresh tokens, so revoking a session ends both.
+CREATE TABLE IF NOT EXISTS sessions (
+    id uuid PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device varchar(100) NOT NULL DEFAULT '',
+    ip_address varchar(64) NOT NULL DEFAULT '',
+    user_agent text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_seen_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    expires_at timestamp(0) with time zone NOT NULL,
+    revoked_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
+
+-- refresh token families issued before sessions existed become sessions
+INSERT INTO sessions (id, user_id, created_at, last_seen_at, expires_at, revoked_at)
+SELECT family_id, MIN(user_id), MIN(created_at), MAX(created_at), MAX(expires_at),
+    CASE WHEN bool_and(revoked_at IS NOT NULL) THEN MAX(revoked_at) END
+FROM refresh_tokens
+GROUP BY family_id
+ON CONFLICT (id) DO NOTHING;
+
+ALTER TABLE refresh_tokens
+    ADD CONSTRAINT refresh_tokens_family_id_fkey FOREIGN KEY (family_id) REFERENCES sessions(id) ON DELETE CASCADE;
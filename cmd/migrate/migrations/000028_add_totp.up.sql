@@ -0,0 +1,17 @@
+-- TOTP second factor. A secret is pending until the first code is verified.
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id bigint PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret varchar(64) NOT NULL, -- base32, needed in the clear to compute codes
+    last_used_step bigint, -- codes of this or earlier steps are refused as replays
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    enabled_at timestamp(0) with time zone
+);
+
+-- one-time recovery codes, stored as sha256 hashes
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash varchar(64) NOT NULL,
+    used_at timestamp(0) with time zone,
+    UNIQUE (user_id, code_hash)
+);
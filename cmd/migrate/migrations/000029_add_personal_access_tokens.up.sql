@@ -0,0 +1,13 @@
+-- long-lived tokens for scripts, stored as sha256 hashes
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name varchar(100) NOT NULL,
+    token_hash varchar(64) NOT NULL UNIQUE,
+    scopes text[] NOT NULL, -- e.g. 'transactions:read', 'transactions:write'
+    expires_at timestamp(0) with time zone NOT NULL,
+    last_used_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS personal_access_tokens_user_id_idx ON personal_access_tokens (user_id);
@@ -0,0 +1,3 @@
+ALTER TABLE budgets DROP CONSTRAINT IF EXISTS budgets_category_id_fkey;
+ALTER TABLE budgets
+    ADD CONSTRAINT budgets_category_id_fkey FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE;
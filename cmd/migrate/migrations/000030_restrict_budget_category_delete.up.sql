@@ -0,0 +1,5 @@
+-- deleting a category must not take its budgets, alerts and envelope ledgers
+-- with it; they are moved to a replacement category first
+ALTER TABLE budgets DROP CONSTRAINT IF EXISTS budgets_category_id_fkey;
+ALTER TABLE budgets
+    ADD CONSTRAINT budgets_category_id_fkey FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE RESTRICT;
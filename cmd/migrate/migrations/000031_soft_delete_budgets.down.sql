@@ -0,0 +1,7 @@
+DELETE FROM budgets WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS budgets_user_id_category_id_period_key;
+ALTER TABLE budgets
+    ADD CONSTRAINT budgets_user_id_category_id_period_key UNIQUE (user_id, category_id, period);
+
+ALTER TABLE budgets DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,8 @@
+-- deleted budgets are only marked, so the envelope ledgers that point at them
+-- through transfers and rollovers stay complete
+ALTER TABLE budgets ADD COLUMN deleted_at timestamp(0) with time zone;
+
+-- a deleted budget does not keep its category and month from being budgeted again
+ALTER TABLE budgets DROP CONSTRAINT IF EXISTS budgets_user_id_category_id_period_key;
+CREATE UNIQUE INDEX IF NOT EXISTS budgets_user_id_category_id_period_key
+    ON budgets (user_id, category_id, period) WHERE deleted_at IS NULL;
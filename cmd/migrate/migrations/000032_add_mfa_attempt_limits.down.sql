@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS mfa_challenges;
+
+ALTER TABLE user_totp DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE user_totp DROP COLUMN IF EXISTS failed_attempts;
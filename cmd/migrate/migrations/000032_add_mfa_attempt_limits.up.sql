@@ -0,0 +1,15 @@
+-- attempts at the second factor since it last succeeded; reaching the limit
+-- locks it until locked_until
+ALTER TABLE user_totp ADD COLUMN failed_attempts int NOT NULL DEFAULT 0;
+ALTER TABLE user_totp ADD COLUMN locked_until timestamp(0) with time zone;
+
+-- challenge tokens issued by logins, keyed by their jti, so each is only good
+-- for one try at the second factor
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+    id uuid PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at timestamp(0) with time zone NOT NULL,
+    used_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS mfa_challenges_user_id_idx ON mfa_challenges (user_id);
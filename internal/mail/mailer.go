@@ -11,6 +11,7 @@ const (
 	maxRetires            = 3
 	UserWelcomeTemplate   = "user_invitation.tmpl"
 	PasswordResetTemplate = "reset_password.tmpl"
+	BudgetAlertTemplate   = "budget_alert.tmpl"
 )
 
 //go:embed "templates"
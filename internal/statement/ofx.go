@@ -0,0 +1,142 @@
+package statement
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNotOFX = errors.New("statement: not an OFX file")
+
+// ParseOFX parses the bank and credit card transactions of an OFX or QFX file.
+// Both the SGML based OFX 1.x, in which elements do not need closing tags, and
+// the XML based OFX 2.x are supported.
+func ParseOFX(r io.Reader) ([]Entry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	content := string(data)
+
+	start := strings.Index(strings.ToUpper(content), "<OFX>")
+	if start < 0 {
+		return nil, ErrNotOFX
+	}
+	line := 1 + strings.Count(content[:start], "\n")
+	content = content[start:]
+
+	var (
+		entries   []Entry
+		current   *Entry
+		trnType   string
+		account   string
+		inAccount bool
+		errs      []error
+	)
+	finish := func() {
+		if current == nil {
+			return
+		}
+		switch trnType {
+		case "DEBIT":
+			current.Amount = -math.Abs(current.Amount)
+		case "CREDIT":
+			current.Amount = math.Abs(current.Amount)
+		}
+		if current.Date.IsZero() && len(errs) == 0 {
+			errs = append(errs, errors.New("missing DTPOSTED"))
+		}
+		current.Account = account
+		current.Err = errors.Join(errs...)
+		entries = append(entries, *current)
+		current, trnType, errs = nil, "", nil
+	}
+
+	for len(content) > 0 {
+		open := strings.IndexByte(content, '<')
+		if open < 0 {
+			break
+		}
+		line += strings.Count(content[:open], "\n")
+		content = content[open+1:]
+
+		end := strings.IndexByte(content, '>')
+		if end < 0 {
+			return nil, fmt.Errorf("statement: unterminated tag on line %d", line)
+		}
+		tag := strings.ToUpper(strings.TrimSpace(content[:end]))
+		content = content[end+1:]
+
+		// the value of a leaf element runs up to the next tag
+		next := strings.IndexByte(content, '<')
+		if next < 0 {
+			next = len(content)
+		}
+		value := html.UnescapeString(strings.TrimSpace(content[:next]))
+
+		switch tag {
+		case "BANKACCTFROM", "CCACCTFROM":
+			inAccount = true
+		case "/BANKACCTFROM", "/CCACCTFROM":
+			inAccount = false
+		case "ACCTID":
+			if inAccount {
+				account = value
+			}
+		case "STMTTRN":
+			finish()
+			current = &Entry{Line: line}
+		case "/STMTTRN", "/BANKTRANLIST":
+			finish()
+		}
+		if current == nil {
+			continue
+		}
+
+		switch tag {
+		case "TRNTYPE":
+			trnType = strings.ToUpper(value)
+		case "DTPOSTED":
+			date, err := parseOFXDate(value)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			current.Date = date
+		case "TRNAMT":
+			amount, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid TRNAMT %q", value))
+			}
+			current.Amount = amount
+		case "FITID":
+			current.FITID = value
+		case "NAME":
+			current.Description = value
+		case "MEMO":
+			if current.Description == "" {
+				current.Description = value
+			}
+		}
+	}
+	finish()
+
+	return entries, nil
+}
+
+// parseOFXDate reads the date part of an OFX datetime such as
+// 20240131120000.000[-5:EST].
+func parseOFXDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid DTPOSTED %q", value)
+	}
+	date, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid DTPOSTED %q", value)
+	}
+	return date, nil
+}
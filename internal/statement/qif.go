@@ -0,0 +1,123 @@
+package statement
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultQIFDateLayout is the month-first layout used by most QIF exports.
+const DefaultQIFDateLayout = "01/02/2006"
+
+// qifTransactionSections are the !Type sections that hold transactions of a
+// bank, cash or credit card account. Other sections, such as category lists,
+// are skipped.
+var qifTransactionSections = map[string]bool{
+	"bank":  true,
+	"cash":  true,
+	"ccard": true,
+	"oth a": true,
+	"oth l": true,
+}
+
+// ParseQIF parses the transactions of a QIF file. QIF does not say how its
+// dates are written, so dateLayout picks between e.g. month-first and
+// day-first dates; days and months may be written without leading zeros and
+// years with two or four digits.
+func ParseQIF(r io.Reader, dateLayout string) ([]Entry, error) {
+	if dateLayout == "" {
+		dateLayout = DefaultQIFDateLayout
+	}
+	layouts := qifDateLayouts(dateLayout)
+
+	var (
+		entries []Entry
+		current *Entry
+		errs    []error
+		section = "bank"
+	)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "!") {
+			header := strings.ToLower(strings.TrimSpace(text[1:]))
+			switch {
+			case strings.HasPrefix(header, "type:"):
+				section = strings.TrimSpace(strings.TrimPrefix(header, "type:"))
+			case header == "account":
+				section = "account"
+			}
+			continue
+		}
+		if !qifTransactionSections[section] {
+			continue
+		}
+
+		if current == nil {
+			current = &Entry{Line: line}
+		}
+		code, value := text[0], strings.TrimSpace(text[1:])
+		switch code {
+		case 'D':
+			date, err := parseQIFDate(value, layouts)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			current.Date = date
+		case 'T', 'U':
+			amount, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid amount %q", value))
+			}
+			current.Amount = amount
+		case 'P':
+			current.Description = value
+		case 'M':
+			if current.Description == "" {
+				current.Description = value
+			}
+		case '^':
+			if current.Date.IsZero() && len(errs) == 0 {
+				errs = append(errs, errors.New("missing date"))
+			}
+			current.Err = errors.Join(errs...)
+			entries = append(entries, *current)
+			current, errs = nil, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		return nil, fmt.Errorf("statement: unterminated QIF record on line %d", current.Line)
+	}
+
+	return entries, nil
+}
+
+// qifDateLayouts relaxes a layout to accept unpadded days and months and two
+// digit years.
+func qifDateLayouts(layout string) []string {
+	relaxed := strings.NewReplacer("01", "1", "02", "2").Replace(layout)
+	return []string{relaxed, strings.Replace(relaxed, "2006", "06", 1)}
+}
+
+// parseQIFDate parses dates such as 1/31/2024, 01/31/24 and the Quicken
+// style 1/31'24.
+func parseQIFDate(value string, layouts []string) (time.Time, error) {
+	normalized := strings.ReplaceAll(strings.ReplaceAll(value, "'", "/"), " ", "")
+	for _, layout := range layouts {
+		if date, err := time.Parse(layout, normalized); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
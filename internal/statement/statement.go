@@ -0,0 +1,41 @@
+// Package statement parses bank statement exports (OFX/QFX and QIF) into a
+// common list of entries.
+package statement
+
+import (
+	"fmt"
+	"time"
+)
+
+// Entry is a single transaction of a statement. Amount is signed: negative
+// amounts are debits and positive amounts are credits.
+type Entry struct {
+	// Line is the line of the file on which the entry starts.
+	Line        int
+	Date        time.Time
+	Amount      float64
+	Description string
+	// FITID is the bank's unique id of the transaction, only set for OFX.
+	FITID string
+	// Account identifies the account the statement belongs to, only set for OFX.
+	Account string
+	// Err is set when the entry could not be parsed completely.
+	Err error
+}
+
+// TransactionType maps debits to "expense" and credits to "income".
+func (e Entry) TransactionType() string {
+	if e.Amount < 0 {
+		return "expense"
+	}
+	return "income"
+}
+
+// ExternalID returns a key that identifies the entry across imports, or "" if
+// the statement format has none.
+func (e Entry) ExternalID() string {
+	if e.FITID == "" {
+		return ""
+	}
+	return fmt.Sprintf("ofx:%s:%s", e.Account, e.FITID)
+}
@@ -0,0 +1,297 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// AnalyticsScope selects the transactions analytics are computed over: the
+// user's personal transactions, the transactions of one group, or with
+// GroupID 0 the transactions of every group the user is a member of.
+type AnalyticsScope struct {
+	UserID  int64
+	Group   bool
+	GroupID int64
+}
+
+// source returns a query selecting the amount, type, date and category of the
+// transactions in scope, with the scope bound to $1, and the value for $1.
+func (s AnalyticsScope) source() (string, any) {
+	const columns = `SELECT amount, transaction_type, transaction_date, category_id`
+	switch {
+	case !s.Group:
+		return columns + ` FROM individual_transactions WHERE user_id = $1`, s.UserID
+	case s.GroupID != 0:
+		return columns + ` FROM group_transactions WHERE group_id = $1`, s.GroupID
+	default:
+		return columns + ` FROM group_transactions WHERE group_id IN (SELECT group_id FROM group_members WHERE user_id = $1)`, s.UserID
+	}
+}
+
+type AnalyticsSummary struct {
+	Income   float64 `json:"income"`
+	Expenses float64 `json:"expenses"`
+	Balance  float64 `json:"balance"`
+}
+
+// MonthlyTrend is the income and expenses of one month. Month is the short
+// month name the charts label their axis with and Period is YYYY-MM.
+type MonthlyTrend struct {
+	Month   string  `json:"month"`
+	Period  string  `json:"period"`
+	Income  float64 `json:"income"`
+	Expense float64 `json:"expense"`
+	Savings float64 `json:"savings"`
+}
+
+// CategoryAmount is the amount spent in a top-level category, including all
+// of its sub-categories.
+type CategoryAmount struct {
+	CategoryID int64   `json:"categoryId"`
+	Name       string  `json:"name"`
+	Value      float64 `json:"value"`
+}
+
+// DailyAmount is the amount spent on a day of the month.
+type DailyAmount struct {
+	Day     int     `json:"day"`
+	Expense float64 `json:"expense"`
+}
+
+type AnalyticsStore struct {
+	db *sql.DB
+}
+
+// Summary sums income and expenses with dates in [from, to).
+func (s *AnalyticsStore) Summary(ctx context.Context, scope AnalyticsScope, from, to time.Time) (*AnalyticsSummary, error) {
+	source, arg := scope.source()
+	query := `
+		SELECT
+			COALESCE(SUM(t.amount) FILTER (WHERE t.transaction_type = 'income'), 0),
+			COALESCE(SUM(t.amount) FILTER (WHERE t.transaction_type = 'expense'), 0)
+		FROM (` + source + `) t
+		WHERE t.transaction_date >= $2::date AND t.transaction_date < $3::date
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	summary := &AnalyticsSummary{}
+	err := s.db.QueryRowContext(ctx, query, arg, from.Format(time.DateOnly), to.Format(time.DateOnly)).
+		Scan(&summary.Income, &summary.Expenses)
+	if err != nil {
+		return nil, err
+	}
+	summary.Balance = roundCents(summary.Income - summary.Expenses)
+	return summary, nil
+}
+
+// Trend returns the income and expenses of every month from the month of from
+// to the month before to, oldest first. Months without transactions are zero.
+func (s *AnalyticsStore) Trend(ctx context.Context, scope AnalyticsScope, from, to time.Time) ([]MonthlyTrend, error) {
+	source, arg := scope.source()
+	query := `
+		WITH totals AS (
+			SELECT date_trunc('month', t.transaction_date)::date AS month,
+				SUM(t.amount) FILTER (WHERE t.transaction_type = 'income') AS income,
+				SUM(t.amount) FILTER (WHERE t.transaction_type = 'expense') AS expense
+			FROM (` + source + `) t
+			WHERE t.transaction_date >= $2::date AND t.transaction_date < $3::date
+			GROUP BY date_trunc('month', t.transaction_date)
+		)
+		SELECT to_char(m.month, 'Mon'), to_char(m.month, 'YYYY-MM'),
+			COALESCE(totals.income, 0), COALESCE(totals.expense, 0)
+		FROM generate_series($2::date, $3::date - interval '1 month', interval '1 month') AS m(month)
+		LEFT JOIN totals ON totals.month = m.month::date
+		ORDER BY m.month
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, arg, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trend := []MonthlyTrend{}
+	for rows.Next() {
+		var month MonthlyTrend
+		if err := rows.Scan(&month.Month, &month.Period, &month.Income, &month.Expense); err != nil {
+			return nil, err
+		}
+		month.Savings = roundCents(month.Income - month.Expense)
+		trend = append(trend, month)
+	}
+	return trend, rows.Err()
+}
+
+// ByCategory sums the expenses with dates in [from, to) per top-level
+// category, rolling the expenses of sub-categories up into their root, largest
+// first.
+func (s *AnalyticsStore) ByCategory(ctx context.Context, scope AnalyticsScope, from, to time.Time) ([]CategoryAmount, error) {
+	source, arg := scope.source()
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT c.id AS root_id, c.id AS category_id
+			FROM categories c
+			WHERE c.parent_id IS NULL
+			UNION
+			SELECT tree.root_id, c.id
+			FROM categories c
+			JOIN tree ON c.parent_id = tree.category_id
+		)
+		SELECT r.id, r.name, SUM(t.amount)
+		FROM (` + source + `) t
+		JOIN tree ON tree.category_id = t.category_id
+		JOIN categories r ON r.id = tree.root_id
+		WHERE t.transaction_type = 'expense' AND t.transaction_date >= $2::date AND t.transaction_date < $3::date
+		GROUP BY r.id, r.name
+		ORDER BY SUM(t.amount) DESC, r.name, r.id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, arg, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	amounts := []CategoryAmount{}
+	for rows.Next() {
+		var amount CategoryAmount
+		if err := rows.Scan(&amount.CategoryID, &amount.Name, &amount.Value); err != nil {
+			return nil, err
+		}
+		amounts = append(amounts, amount)
+	}
+	return amounts, rows.Err()
+}
+
+// Daily returns the expenses of every day of the month starting at month.
+// Days without expenses are zero.
+func (s *AnalyticsStore) Daily(ctx context.Context, scope AnalyticsScope, month time.Time) ([]DailyAmount, error) {
+	source, arg := scope.source()
+	query := `
+		WITH totals AS (
+			SELECT date_trunc('day', t.transaction_date)::date AS day, SUM(t.amount) AS expense
+			FROM (` + source + `) t
+			WHERE t.transaction_type = 'expense'
+				AND t.transaction_date >= $2::date AND t.transaction_date < $2::date + interval '1 month'
+			GROUP BY date_trunc('day', t.transaction_date)
+		)
+		SELECT EXTRACT(DAY FROM d.day)::int, COALESCE(totals.expense, 0)
+		FROM generate_series($2::date, $2::date + interval '1 month' - interval '1 day', interval '1 day') AS d(day)
+		LEFT JOIN totals ON totals.day = d.day::date
+		ORDER BY d.day
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, arg, month.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []DailyAmount{}
+	for rows.Next() {
+		var day DailyAmount
+		if err := rows.Scan(&day.Day, &day.Expense); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+// CategoryAverage is the mean and sample variance of the monthly totals of one
+// category and transaction type.
+type CategoryAverage struct {
+	CategoryID      int64   `json:"categoryId"`
+	CategoryName    string  `json:"categoryName"`
+	TransactionType string  `json:"transactionType"`
+	Average         float64 `json:"average"`
+	Variance        float64 `json:"variance"`
+}
+
+// Balance returns the user's income minus expenses over all transactions.
+func (s *AnalyticsStore) Balance(ctx context.Context, userID int64) (float64, error) {
+	query := `
+		SELECT COALESCE(SUM(CASE WHEN transaction_type = 'income' THEN amount ELSE -amount END), 0)
+		FROM individual_transactions
+		WHERE user_id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var balance float64
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&balance)
+	return balance, err
+}
+
+// CategoryAverages returns the average monthly total per category and type of
+// the user's transactions in the months of [from, to), leaving out those
+// posted by recurring transactions. Months before the user's first
+// transaction are not counted, so a short history is not averaged down.
+func (s *AnalyticsStore) CategoryAverages(ctx context.Context, userID int64, from, to time.Time) ([]CategoryAverage, error) {
+	query := `
+		WITH history AS (
+			SELECT category_id, transaction_type, transaction_date, amount
+			FROM individual_transactions
+			WHERE user_id = $1 AND recurring_transaction_id IS NULL
+				AND transaction_date < $3::date
+		),
+		months AS (
+			SELECT generate_series(
+				GREATEST($2::date, (SELECT date_trunc('month', MIN(transaction_date))::date FROM history)),
+				$3::date - interval '1 month',
+				interval '1 month'
+			)::date AS month
+		),
+		totals AS (
+			SELECT category_id, transaction_type, date_trunc('month', transaction_date)::date AS month, SUM(amount) AS amount
+			FROM history
+			WHERE transaction_date >= $2::date
+			GROUP BY category_id, transaction_type, date_trunc('month', transaction_date)
+		),
+		keys AS (
+			SELECT DISTINCT category_id, transaction_type FROM totals
+		)
+		SELECT k.category_id, c.name, k.transaction_type,
+			AVG(COALESCE(t.amount, 0)),
+			COALESCE(VAR_SAMP(COALESCE(t.amount, 0)), 0)
+		FROM keys k
+		CROSS JOIN months m
+		LEFT JOIN totals t ON t.category_id = k.category_id AND t.transaction_type = k.transaction_type AND t.month = m.month
+		JOIN categories c ON c.id = k.category_id
+		GROUP BY k.category_id, c.name, k.transaction_type
+		ORDER BY k.transaction_type, c.name
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	averages := []CategoryAverage{}
+	for rows.Next() {
+		var average CategoryAverage
+		err := rows.Scan(
+			&average.CategoryID,
+			&average.CategoryName,
+			&average.TransactionType,
+			&average.Average,
+			&average.Variance,
+		)
+		if err != nil {
+			return nil, err
+		}
+		averages = append(averages, average)
+	}
+	return averages, rows.Err()
+}
@@ -0,0 +1,214 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"strings"
+)
+
+// Reasons a transaction is flagged as unusual.
+const (
+	AnomalyReasonAmountOutlier = "amount_outlier"
+	AnomalyReasonNewMerchant   = "new_merchant"
+)
+
+// AnomalyRules configures when an expense is flagged. An expense is an outlier
+// when it is more than StdDevs standard deviations above or below the mean of
+// the user's expenses in the same category over the preceding WindowDays,
+// provided there are at least MinSamples of them. An expense of at least
+// NewMerchantAmount whose description the user has never used before is a new
+// merchant; a zero amount disables that rule.
+type AnomalyRules struct {
+	StdDevs           float64
+	WindowDays        int
+	MinSamples        int
+	NewMerchantAmount float64
+}
+
+// TransactionAnomaly is the stored flag of an unusual transaction. Mean,
+// StdDev and Score are only set for outliers.
+type TransactionAnomaly struct {
+	Reason     string  `json:"reason"`
+	Mean       float64 `json:"mean,omitempty"`
+	StdDev     float64 `json:"stdDev,omitempty"`
+	Score      float64 `json:"score,omitempty"`
+	DetectedAt string  `json:"detectedAt"`
+}
+
+// anomalyColumns selects the flag of a transaction joined as a.
+const anomalyColumns = `a.reason, a.mean, a.std_dev, a.score, a.detected_at`
+
+// nullAnomaly scans the columns of a possibly missing flag.
+type nullAnomaly struct {
+	reason     sql.NullString
+	mean       sql.NullFloat64
+	stdDev     sql.NullFloat64
+	score      sql.NullFloat64
+	detectedAt sql.NullString
+}
+
+func (n *nullAnomaly) dest() []any {
+	return []any{&n.reason, &n.mean, &n.stdDev, &n.score, &n.detectedAt}
+}
+
+func (n *nullAnomaly) anomaly() *TransactionAnomaly {
+	if !n.reason.Valid {
+		return nil
+	}
+	return &TransactionAnomaly{
+		Reason:     n.reason.String,
+		Mean:       n.mean.Float64,
+		StdDev:     n.stdDev.Float64,
+		Score:      n.score.Float64,
+		DetectedAt: n.detectedAt.String,
+	}
+}
+
+// DetectAnomaly checks a stored transaction against the rules and stores or
+// clears its flag. Only expenses are checked. The flag is also set on the
+// transaction.
+func (t *TransactionStore) DetectAnomaly(ctx context.Context, transaction *Transaction, rules AnomalyRules) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	anomaly, err := t.detectAnomaly(ctx, transaction, rules)
+	if err != nil {
+		return err
+	}
+
+	if anomaly == nil {
+		_, err := t.db.ExecContext(ctx, `DELETE FROM transaction_anomalies WHERE transaction_id = $1`, transaction.ID)
+		if err == nil {
+			transaction.Anomaly = nil
+		}
+		return err
+	}
+
+	query := `
+		INSERT INTO transaction_anomalies (transaction_id, user_id, reason, mean, std_dev, score)
+		VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, 0), NULLIF($6, 0))
+		ON CONFLICT (transaction_id) DO UPDATE
+		SET reason = EXCLUDED.reason, mean = EXCLUDED.mean, std_dev = EXCLUDED.std_dev, score = EXCLUDED.score,
+			detected_at = NOW()
+		RETURNING detected_at
+	`
+	err = t.db.QueryRowContext(ctx, query,
+		transaction.ID,
+		transaction.UserID,
+		anomaly.Reason,
+		anomaly.Mean,
+		anomaly.StdDev,
+		anomaly.Score,
+	).Scan(&anomaly.DetectedAt)
+	if err != nil {
+		return err
+	}
+	transaction.Anomaly = anomaly
+	return nil
+}
+
+func (t *TransactionStore) detectAnomaly(ctx context.Context, transaction *Transaction, rules AnomalyRules) (*TransactionAnomaly, error) {
+	if transaction.TransactionType != "expense" {
+		return nil, nil
+	}
+
+	query := `
+		SELECT COUNT(*), COALESCE(AVG(amount), 0), COALESCE(STDDEV_SAMP(amount), 0)
+		FROM individual_transactions
+		WHERE user_id = $1 AND category_id = $2 AND transaction_type = 'expense' AND id <> $3
+			AND transaction_date < $4::timestamptz
+			AND transaction_date >= $4::timestamptz - make_interval(days => $5)
+	`
+	var samples int
+	var mean, stdDev float64
+	err := t.db.QueryRowContext(ctx, query,
+		transaction.UserID,
+		transaction.CategoryID,
+		transaction.ID,
+		transaction.TransactionDate,
+		rules.WindowDays,
+	).Scan(&samples, &mean, &stdDev)
+	if err != nil {
+		return nil, err
+	}
+	if samples >= rules.MinSamples && stdDev > 0 {
+		if score := (transaction.Amount - mean) / stdDev; math.Abs(score) > rules.StdDevs {
+			return &TransactionAnomaly{
+				Reason: AnomalyReasonAmountOutlier,
+				Mean:   roundCents(mean),
+				StdDev: roundCents(stdDev),
+				Score:  roundCents(score),
+			}, nil
+		}
+	}
+
+	merchant := strings.TrimSpace(transaction.Description)
+	if rules.NewMerchantAmount <= 0 || merchant == "" || transaction.Amount < rules.NewMerchantAmount {
+		return nil, nil
+	}
+	query = `
+		SELECT EXISTS(
+			SELECT 1 FROM individual_transactions
+			WHERE user_id = $1 AND id <> $2 AND lower(trim(description)) = lower($3)
+				AND transaction_date <= $4::timestamptz
+		)
+	`
+	var seen bool
+	if err := t.db.QueryRowContext(ctx, query, transaction.UserID, transaction.ID, merchant, transaction.TransactionDate).Scan(&seen); err != nil {
+		return nil, err
+	}
+	if seen {
+		return nil, nil
+	}
+	return &TransactionAnomaly{Reason: AnomalyReasonNewMerchant}, nil
+}
+
+// ListAnomalies returns the user's flagged transactions with dates between
+// startDate and endDate (YYYY-MM-DD, inclusive, either may be empty), newest
+// first.
+func (t *TransactionStore) ListAnomalies(ctx context.Context, userID int64, startDate, endDate string) ([]Transaction, error) {
+	query := `
+		SELECT t.id, t.user_id, t.amount, t.category_id, c.name, t.transaction_type, COALESCE(t.description, ''),
+			t.created_at, t.updated_at, t.transaction_date, ` + anomalyColumns + `
+		FROM transaction_anomalies a
+		JOIN individual_transactions t ON t.id = a.transaction_id
+		JOIN categories c ON c.id = t.category_id
+		WHERE a.user_id = $1
+			AND ($2 = '' OR t.transaction_date >= to_date(NULLIF($2, ''), 'YYYY-MM-DD'))
+			AND ($3 = '' OR t.transaction_date < to_date(NULLIF($3, ''), 'YYYY-MM-DD') + 1)
+		ORDER BY t.transaction_date DESC, t.id DESC
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := t.db.QueryContext(ctx, query, userID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []Transaction{}
+	for rows.Next() {
+		var transaction Transaction
+		var flag nullAnomaly
+		dest := []any{
+			&transaction.ID,
+			&transaction.UserID,
+			&transaction.Amount,
+			&transaction.CategoryID,
+			&transaction.CategoryName,
+			&transaction.TransactionType,
+			&transaction.Description,
+			&transaction.CreatedAt,
+			&transaction.UpdatedAt,
+			&transaction.TransactionDate,
+		}
+		if err := rows.Scan(append(dest, flag.dest()...)...); err != nil {
+			return nil, err
+		}
+		transaction.Anomaly = flag.anomaly()
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
@@ -0,0 +1,608 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrDuplicateBudget   = errors.New("a budget for this category and month already exists")
+	ErrInsufficientFunds = errors.New("the envelope does not hold enough money")
+)
+
+// Kinds of budget ledger entries.
+const (
+	AllocationKindAllocation = "allocation"
+	AllocationKindRollover   = "rollover"
+	AllocationKindTransfer   = "transfer"
+)
+
+// Budget caps the expenses of a category, including its sub-categories, in one
+// month. Month is formatted as YYYY-MM. An envelope budget holds whatever its
+// allocation ledger adds up to instead of a fixed limit.
+type Budget struct {
+	ID           int64   `json:"id"`
+	UserID       int64   `json:"userId"`
+	CategoryID   int64   `json:"categoryId"`
+	CategoryName string  `json:"categoryName"`
+	Month        string  `json:"month"`
+	Limit        float64 `json:"limit"`
+	Envelope     bool    `json:"envelope"`
+	CreatedAt    string  `json:"createdAt"`
+	UpdatedAt    string  `json:"updatedAt"`
+}
+
+// BudgetStatus is a budget together with what has been spent against it.
+// Available is the limit, or for an envelope the sum of its ledger. Remaining
+// is negative once the budget is overspent.
+type BudgetStatus struct {
+	Budget
+	Available   float64 `json:"available"`
+	Spent       float64 `json:"spent"`
+	Remaining   float64 `json:"remaining"`
+	PercentUsed float64 `json:"percentUsed"`
+}
+
+// BudgetAllocation is an entry of an envelope's ledger. Amount is negative when
+// money leaves the envelope. RelatedBudgetID is the other envelope of a
+// rollover or transfer.
+type BudgetAllocation struct {
+	ID              int64   `json:"id"`
+	BudgetID        int64   `json:"budgetId"`
+	Kind            string  `json:"kind"`
+	Amount          float64 `json:"amount"`
+	RelatedBudgetID *int64  `json:"relatedBudgetId,omitempty"`
+	Note            string  `json:"note,omitempty"`
+	CreatedAt       string  `json:"createdAt"`
+}
+
+type BudgetStore struct {
+	db *sql.DB
+}
+
+// Create inserts a budget. The limit of an envelope budget is recorded as its
+// first allocation.
+func (s *BudgetStore) Create(ctx context.Context, budget *Budget) error {
+	query := `
+		INSERT INTO budgets (user_id, category_id, period, limit_amount, envelope)
+		VALUES ($1, $2, to_date($3, 'YYYY-MM'), $4, $5)
+		RETURNING id, created_at, updated_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, budget.UserID, budget.CategoryID, budget.Month, budget.Limit, budget.Envelope).
+			Scan(&budget.ID, &budget.CreatedAt, &budget.UpdatedAt)
+		if err != nil {
+			switch {
+			case err.Error() == `pq: duplicate key value violates unique constraint "budgets_user_id_category_id_period_key"`:
+				return ErrDuplicateBudget
+			default:
+				return err
+			}
+		}
+		if !budget.Envelope || budget.Limit == 0 {
+			return nil
+		}
+		_, err = insertAllocation(ctx, tx, budget.ID, AllocationKindAllocation, budget.Limit, nil, "")
+		return err
+	})
+}
+
+const budgetColumns = `
+	b.id, b.user_id, b.category_id, c.name, to_char(b.period, 'YYYY-MM'), b.limit_amount, b.envelope, b.created_at, b.updated_at
+`
+
+func scanBudget(row rowScanner, budget *Budget, extra ...any) error {
+	dest := []any{
+		&budget.ID,
+		&budget.UserID,
+		&budget.CategoryID,
+		&budget.CategoryName,
+		&budget.Month,
+		&budget.Limit,
+		&budget.Envelope,
+		&budget.CreatedAt,
+		&budget.UpdatedAt,
+	}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// ListByUser returns the user's budgets, newest month first. A non-empty month
+// (YYYY-MM) limits the result to that month.
+func (s *BudgetStore) ListByUser(ctx context.Context, userID int64, month string) ([]Budget, error) {
+	query := `
+		SELECT ` + budgetColumns + `
+		FROM budgets b
+		JOIN categories c ON c.id = b.category_id
+		WHERE b.user_id = $1 AND b.deleted_at IS NULL
+			AND ($2 = '' OR b.period = to_date(NULLIF($2, ''), 'YYYY-MM'))
+		ORDER BY b.period DESC, c.name
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, month)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	budgets := []Budget{}
+	for rows.Next() {
+		var budget Budget
+		if err := scanBudget(rows, &budget); err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, budget)
+	}
+	return budgets, rows.Err()
+}
+
+func (s *BudgetStore) GetByID(ctx context.Context, id int64) (*Budget, error) {
+	query := `
+		SELECT ` + budgetColumns + `
+		FROM budgets b
+		JOIN categories c ON c.id = b.category_id
+		WHERE b.id = $1 AND b.deleted_at IS NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	budget := &Budget{}
+	if err := scanBudget(s.db.QueryRowContext(ctx, query, id), budget); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return budget, nil
+}
+
+// Update changes the limit of a budget. For an envelope the difference to the
+// old limit is recorded as an allocation.
+func (s *BudgetStore) Update(ctx context.Context, budget *Budget) error {
+	query := `
+		UPDATE budgets
+		SET limit_amount = $1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING updated_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var oldLimit float64
+		var envelope bool
+		err := tx.QueryRowContext(ctx, `SELECT limit_amount, envelope FROM budgets WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, budget.ID).
+			Scan(&oldLimit, &envelope)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		if err := tx.QueryRowContext(ctx, query, budget.Limit, budget.ID).Scan(&budget.UpdatedAt); err != nil {
+			return err
+		}
+
+		delta := roundCents(budget.Limit - oldLimit)
+		if !envelope || delta == 0 {
+			return nil
+		}
+		note := fmt.Sprintf("limit changed from %.2f to %.2f", oldLimit, budget.Limit)
+		_, err = insertAllocation(ctx, tx, budget.ID, AllocationKindAllocation, delta, nil, note)
+		return err
+	})
+}
+
+// Delete marks a budget as deleted. The row is kept so that the ledgers of
+// envelopes it exchanged money with still add up and point somewhere.
+func (s *BudgetStore) Delete(ctx context.Context, id int64) error {
+	query := `UPDATE budgets SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// StatusByMonth returns every budget of the user in the month (YYYY-MM) with
+// the expenses of its category and all of its sub-categories in that month.
+func (s *BudgetStore) StatusByMonth(ctx context.Context, userID int64, month string) ([]BudgetStatus, error) {
+	return s.status(ctx, s.db, "", userID, month)
+}
+
+// StatusForCategory returns the budgets in the month (YYYY-MM) that a
+// transaction of the category counts against: the budget of the category
+// itself and those of its parent categories.
+func (s *BudgetStore) StatusForCategory(ctx context.Context, userID, categoryID int64, month string) ([]BudgetStatus, error) {
+	condition := `AND b.category_id IN (
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM categories WHERE id = $3
+			UNION
+			SELECT c.id, c.parent_id FROM categories c JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT id FROM ancestors
+	)`
+	return s.status(ctx, s.db, condition, userID, month, categoryID)
+}
+
+// status computes the budget status of the user's budgets in a month. $1 is the
+// user and $2 the month; condition can narrow the budgets further.
+func (s *BudgetStore) status(ctx context.Context, q querier, condition string, args ...any) ([]BudgetStatus, error) {
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT b.id AS budget_id, b.category_id
+			FROM budgets b
+			WHERE b.user_id = $1 AND b.period = to_date($2, 'YYYY-MM') AND b.deleted_at IS NULL ` + condition + `
+			UNION
+			SELECT tree.budget_id, c.id
+			FROM categories c
+			JOIN tree ON c.parent_id = tree.category_id
+		),
+		spent AS (
+			SELECT tree.budget_id, SUM(t.amount) AS amount
+			FROM tree
+			JOIN individual_transactions t ON t.category_id = tree.category_id
+			WHERE t.user_id = $1
+				AND t.transaction_type = 'expense'
+				AND t.transaction_date >= to_date($2, 'YYYY-MM')
+				AND t.transaction_date < to_date($2, 'YYYY-MM') + interval '1 month'
+			GROUP BY tree.budget_id
+		),
+		totals AS (
+			SELECT b.id AS budget_id,
+				COALESCE(spent.amount, 0) AS spent,
+				CASE WHEN b.envelope
+					THEN (SELECT COALESCE(SUM(a.amount), 0) FROM budget_allocations a WHERE a.budget_id = b.id)
+					ELSE b.limit_amount
+				END AS available
+			FROM budgets b
+			LEFT JOIN spent ON spent.budget_id = b.id
+			WHERE b.user_id = $1 AND b.period = to_date($2, 'YYYY-MM') AND b.deleted_at IS NULL ` + condition + `
+		)
+		SELECT ` + budgetColumns + `,
+			totals.available,
+			totals.spent,
+			totals.available - totals.spent,
+			CASE
+				WHEN totals.available > 0 THEN ROUND(totals.spent * 100 / totals.available, 2)
+				WHEN totals.spent > 0 THEN 100
+				ELSE 0
+			END
+		FROM budgets b
+		JOIN categories c ON c.id = b.category_id
+		JOIN totals ON totals.budget_id = b.id
+		ORDER BY c.name
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := []BudgetStatus{}
+	for rows.Next() {
+		var status BudgetStatus
+		if err := scanBudget(rows, &status.Budget, &status.Available, &status.Spent, &status.Remaining, &status.PercentUsed); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, rows.Err()
+}
+
+// RecordAlert stores that a threshold (a percentage of the limit) of a budget
+// was reached. It returns false if the alert had already been recorded, so
+// every threshold fires at most once per budget.
+func (s *BudgetStore) RecordAlert(ctx context.Context, budgetID int64, threshold int) (bool, error) {
+	query := `
+		INSERT INTO budget_alerts (budget_id, threshold)
+		VALUES ($1, $2)
+		ON CONFLICT (budget_id, threshold) DO NOTHING
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, budgetID, threshold)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// DeleteAlert forgets a recorded alert so that it can fire again.
+func (s *BudgetStore) DeleteAlert(ctx context.Context, budgetID int64, threshold int) error {
+	query := `DELETE FROM budget_alerts WHERE budget_id = $1 AND threshold = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, budgetID, threshold)
+	return err
+}
+
+// ListAllocations returns the ledger of a budget, oldest entry first.
+func (s *BudgetStore) ListAllocations(ctx context.Context, budgetID int64) ([]BudgetAllocation, error) {
+	query := `
+		SELECT id, budget_id, kind, amount, related_budget_id, COALESCE(note, ''), created_at
+		FROM budget_allocations
+		WHERE budget_id = $1
+		ORDER BY created_at, id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, budgetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	allocations := []BudgetAllocation{}
+	for rows.Next() {
+		var allocation BudgetAllocation
+		var relatedBudgetID sql.NullInt64
+		err := rows.Scan(
+			&allocation.ID,
+			&allocation.BudgetID,
+			&allocation.Kind,
+			&allocation.Amount,
+			&relatedBudgetID,
+			&allocation.Note,
+			&allocation.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if relatedBudgetID.Valid {
+			allocation.RelatedBudgetID = &relatedBudgetID.Int64
+		}
+		allocations = append(allocations, allocation)
+	}
+	return allocations, rows.Err()
+}
+
+// RollOver carries what is left (or overspent) in every envelope of the month
+// before month (YYYY-MM) into the same category's envelope in month, creating
+// the envelope when it does not exist yet. Running it again only records the
+// difference to what was already carried over, so it can be repeated after
+// late transactions. It returns the ledger entries it added.
+func (s *BudgetStore) RollOver(ctx context.Context, userID int64, month string) ([]BudgetAllocation, error) {
+	period, err := time.Parse("2006-01", month)
+	if err != nil {
+		return nil, err
+	}
+	previousMonth := period.AddDate(0, -1, 0).Format("2006-01")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	allocations := []BudgetAllocation{}
+	err = withTx(s.db, ctx, func(tx *sql.Tx) error {
+		previous, err := s.status(ctx, tx, "AND b.envelope", userID, previousMonth)
+		if err != nil {
+			return err
+		}
+
+		for _, from := range previous {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO budgets (user_id, category_id, period, limit_amount, envelope)
+				VALUES ($1, $2, to_date($3, 'YYYY-MM'), 0, true)
+				ON CONFLICT (user_id, category_id, period) WHERE deleted_at IS NULL DO NOTHING
+			`, userID, from.CategoryID, month)
+			if err != nil {
+				return err
+			}
+
+			var toID int64
+			var envelope bool
+			err = tx.QueryRowContext(ctx, `
+				SELECT id, envelope FROM budgets
+				WHERE user_id = $1 AND category_id = $2 AND period = to_date($3, 'YYYY-MM') AND deleted_at IS NULL
+				FOR UPDATE
+			`, userID, from.CategoryID, month).Scan(&toID, &envelope)
+			if err != nil {
+				return err
+			}
+			// a fixed budget for the category was set up this month, leave it alone
+			if !envelope {
+				continue
+			}
+
+			var carried float64
+			err = tx.QueryRowContext(ctx, `
+				SELECT COALESCE(SUM(amount), 0) FROM budget_allocations
+				WHERE budget_id = $1 AND kind = $2 AND related_budget_id = $3
+			`, toID, AllocationKindRollover, from.ID).Scan(&carried)
+			if err != nil {
+				return err
+			}
+
+			delta := roundCents(from.Remaining - carried)
+			if delta == 0 {
+				continue
+			}
+			fromID := from.ID
+			note := fmt.Sprintf("rollover from %s", previousMonth)
+			allocation, err := insertAllocation(ctx, tx, toID, AllocationKindRollover, delta, &fromID, note)
+			if err != nil {
+				return err
+			}
+			allocations = append(allocations, *allocation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allocations, nil
+}
+
+// Transfer moves amount from one envelope to another of the same month. The
+// move is recorded in both ledgers and fails with ErrInsufficientFunds if the
+// source envelope does not have that much left.
+func (s *BudgetStore) Transfer(ctx context.Context, from, to *Budget, amount float64, note string) ([]BudgetAllocation, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var allocations []BudgetAllocation
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		// lock both envelopes in a fixed order so concurrent transfers cannot deadlock
+		_, err := tx.ExecContext(ctx, `SELECT id FROM budgets WHERE id IN ($1, $2) ORDER BY id FOR UPDATE`, from.ID, to.ID)
+		if err != nil {
+			return err
+		}
+
+		statuses, err := s.status(ctx, tx, "AND b.id = $3", from.UserID, from.Month, from.ID)
+		if err != nil {
+			return err
+		}
+		if len(statuses) == 0 {
+			return ErrNotFound
+		}
+		if roundCents(statuses[0].Remaining) < roundCents(amount) {
+			return ErrInsufficientFunds
+		}
+
+		debit, err := insertAllocation(ctx, tx, from.ID, AllocationKindTransfer, -amount, &to.ID, note)
+		if err != nil {
+			return err
+		}
+		credit, err := insertAllocation(ctx, tx, to.ID, AllocationKindTransfer, amount, &from.ID, note)
+		if err != nil {
+			return err
+		}
+		allocations = []BudgetAllocation{*debit, *credit}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allocations, nil
+}
+
+// moveBudgets moves the budgets of one category to another, deleted ones
+// included. A budget that collides with the other category's budget of the
+// same user and month is merged into it: the limits are added up, and its
+// ledger and alerts move over.
+func moveBudgets(ctx context.Context, tx *sql.Tx, fromCategoryID, toCategoryID int64) error {
+	type merge struct {
+		fromID, toID     int64
+		limit            float64
+		envelope, target bool
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT b.id, t.id, b.limit_amount, b.envelope, t.envelope
+		FROM budgets b
+		JOIN budgets t ON t.user_id = b.user_id AND t.period = b.period AND t.category_id = $2
+			AND t.deleted_at IS NULL
+		WHERE b.category_id = $1 AND b.deleted_at IS NULL
+		ORDER BY b.id
+		FOR UPDATE
+	`, fromCategoryID, toCategoryID)
+	if err != nil {
+		return err
+	}
+	var merges []merge
+	for rows.Next() {
+		var m merge
+		if err := rows.Scan(&m.fromID, &m.toID, &m.limit, &m.envelope, &m.target); err != nil {
+			rows.Close()
+			return err
+		}
+		merges = append(merges, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range merges {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE budgets SET limit_amount = limit_amount + $2, updated_at = NOW() WHERE id = $1
+		`, m.toID, m.limit)
+		if err != nil {
+			return err
+		}
+		for _, query := range []string{
+			`UPDATE budget_allocations SET budget_id = $1 WHERE budget_id = $2`,
+			`UPDATE budget_allocations SET related_budget_id = $1 WHERE related_budget_id = $2`,
+			`INSERT INTO budget_alerts (budget_id, threshold, sent_at)
+				SELECT $1, threshold, sent_at FROM budget_alerts WHERE budget_id = $2
+				ON CONFLICT (budget_id, threshold) DO NOTHING`,
+			`DELETE FROM budget_alerts WHERE budget_id = $2`,
+		} {
+			if _, err := tx.ExecContext(ctx, query, m.toID, m.fromID); err != nil {
+				return err
+			}
+		}
+		// an envelope holds what its ledger adds up to, so a fixed limit merged
+		// into one has to be allocated
+		if m.target && !m.envelope && m.limit > 0 {
+			note := fmt.Sprintf("limit of merged budget %d", m.fromID)
+			if _, err := insertAllocation(ctx, tx, m.toID, AllocationKindAllocation, m.limit, nil, note); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM budgets WHERE id = $1`, m.fromID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE budgets SET category_id = $2, updated_at = NOW() WHERE category_id = $1
+	`, fromCategoryID, toCategoryID)
+	return err
+}
+
+func insertAllocation(ctx context.Context, tx *sql.Tx, budgetID int64, kind string, amount float64, relatedBudgetID *int64, note string) (*BudgetAllocation, error) {
+	query := `
+		INSERT INTO budget_allocations (budget_id, kind, amount, related_budget_id, note)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		RETURNING id, created_at
+	`
+	allocation := &BudgetAllocation{
+		BudgetID:        budgetID,
+		Kind:            kind,
+		Amount:          amount,
+		RelatedBudgetID: relatedBudgetID,
+		Note:            note,
+	}
+	err := tx.QueryRowContext(ctx, query, budgetID, kind, amount, relatedBudgetID, note).
+		Scan(&allocation.ID, &allocation.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return allocation, nil
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
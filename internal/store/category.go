@@ -3,32 +3,76 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+)
+
+var (
+	ErrDuplicateCategory   = errors.New("a category with that name already exists")
+	ErrCategoryInUse       = errors.New("category is used by transactions, budgets or goals, provide a replacement category")
+	ErrCategoryHasChildren = errors.New("category has sub-categories, delete or move them first")
 )
 
 type CategoryStore struct {
 	db *sql.DB
 }
 
+// Category is either a system default, visible to everyone, or a custom
+// category owned by a single user. Categories can be nested under a parent of
+// the same type, e.g. "Food > Groceries".
 type Category struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
-	Type string `json:"type"`
+	ID       int64       `json:"id"`
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`
+	OwnerID  *int64      `json:"ownerId"`
+	ParentID *int64      `json:"parentId"`
+	Children []*Category `json:"children,omitempty"`
+}
+
+// CategoryTotal is the sum of a category's transactions. Amount only covers the
+// category itself while Total also includes all of its sub-categories.
+type CategoryTotal struct {
+	CategoryID int64            `json:"categoryId"`
+	Name       string           `json:"name"`
+	Type       string           `json:"type"`
+	ParentID   *int64           `json:"parentId"`
+	Amount     float64          `json:"amount"`
+	Total      float64          `json:"total"`
+	Children   []*CategoryTotal `json:"children,omitempty"`
 }
 
 func (c *CategoryStore) Create(ctx context.Context, category *Category) (*Category, error) {
-	// TODO: Implement the Create method for CategoryStore
-	return nil, nil
+	query := `
+		INSERT INTO categories (name, type, owner_id, parent_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := c.db.QueryRowContext(ctx, query, category.Name, category.Type, category.OwnerID, category.ParentID).Scan(&category.ID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "categories_owner_id_name_key"`:
+			return nil, ErrDuplicateCategory
+		default:
+			return nil, err
+		}
+	}
+	return category, nil
 }
 
-func (c *CategoryStore) ListCategories(ctx context.Context) ([]*Category, error) {
+// ListCategories returns the system categories together with the user's own.
+func (c *CategoryStore) ListCategories(ctx context.Context, userID int64) ([]*Category, error) {
 	query := `
-		SELECT id, name, type
+		SELECT id, name, type, owner_id, parent_id
 		FROM categories
+		WHERE owner_id IS NULL OR owner_id = $1
+		ORDER BY name
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := c.db.QueryContext(ctx, query)
+	rows, err := c.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -37,7 +81,7 @@ func (c *CategoryStore) ListCategories(ctx context.Context) ([]*Category, error)
 	var categories []*Category
 	for rows.Next() {
 		category := &Category{}
-		if err := rows.Scan(&category.ID, &category.Name, &category.Type); err != nil {
+		if err := rows.Scan(&category.ID, &category.Name, &category.Type, &category.OwnerID, &category.ParentID); err != nil {
 			return nil, err
 		}
 		categories = append(categories, category)
@@ -45,17 +89,41 @@ func (c *CategoryStore) ListCategories(ctx context.Context) ([]*Category, error)
 	return categories, nil
 }
 
-func (c *CategoryStore) GetByName(ctx context.Context, name string) (*Category, error) {
+func (c *CategoryStore) GetByID(ctx context.Context, categoryID int64) (*Category, error) {
+	query := `
+		SELECT id, name, type, owner_id, parent_id
+		FROM categories
+		WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	category := &Category{}
+	err := c.db.QueryRowContext(ctx, query, categoryID).Scan(&category.ID, &category.Name, &category.Type, &category.OwnerID, &category.ParentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return category, nil
+}
+
+// GetByName resolves a category name for a user, preferring the user's own
+// categories over the system defaults.
+func (c *CategoryStore) GetByName(ctx context.Context, userID int64, name string) (*Category, error) {
 	query := `
-		SELECT id, name, type
+		SELECT id, name, type, owner_id, parent_id
 		FROM categories
-		WHERE name = $1
+		WHERE name = $1 AND (owner_id = $2 OR owner_id IS NULL)
+		ORDER BY owner_id NULLS LAST
+		LIMIT 1
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
 	category := &Category{}
-	err := c.db.QueryRowContext(ctx, query, name).Scan(&category.ID, &category.Name, &category.Type)
+	err := c.db.QueryRowContext(ctx, query, name, userID).Scan(&category.ID, &category.Name, &category.Type, &category.OwnerID, &category.ParentID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound // No category found
@@ -64,3 +132,185 @@ func (c *CategoryStore) GetByName(ctx context.Context, name string) (*Category,
 	}
 	return category, nil
 }
+
+func (c *CategoryStore) Update(ctx context.Context, category *Category) error {
+	query := `UPDATE categories SET name = $1, parent_id = $2 WHERE id = $3 AND owner_id = $4`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := c.db.ExecContext(ctx, query, category.Name, category.ParentID, category.ID, category.OwnerID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "categories_owner_id_name_key"`:
+			return ErrDuplicateCategory
+		default:
+			return err
+		}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete removes a category. Transactions, budgets and goals still using it are
+// moved to the replacement category; without one (replacementID 0) the delete
+// fails with ErrCategoryInUse while any of them references the category.
+func (c *CategoryStore) Delete(ctx context.Context, categoryID, replacementID int64) error {
+	return withTx(c.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var hasChildren bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM categories WHERE parent_id = $1)`, categoryID).Scan(&hasChildren); err != nil {
+			return err
+		}
+		if hasChildren {
+			return ErrCategoryHasChildren
+		}
+
+		if replacementID == 0 {
+			query := `
+				SELECT EXISTS(SELECT 1 FROM individual_transactions WHERE category_id = $1)
+					OR EXISTS(SELECT 1 FROM group_transactions WHERE category_id = $1)
+					OR EXISTS(SELECT 1 FROM recurring_transactions WHERE category_id = $1)
+					OR EXISTS(SELECT 1 FROM budgets WHERE category_id = $1)
+					OR EXISTS(SELECT 1 FROM goals WHERE category_id = $1)
+			`
+			var inUse bool
+			if err := tx.QueryRowContext(ctx, query, categoryID).Scan(&inUse); err != nil {
+				return err
+			}
+			if inUse {
+				return ErrCategoryInUse
+			}
+		} else {
+			for _, query := range []string{
+				`UPDATE individual_transactions SET category_id = $1 WHERE category_id = $2`,
+				`UPDATE group_transactions SET category_id = $1 WHERE category_id = $2`,
+				`UPDATE recurring_transactions SET category_id = $1 WHERE category_id = $2`,
+				`UPDATE goals SET category_id = $1 WHERE category_id = $2`,
+			} {
+				if _, err := tx.ExecContext(ctx, query, replacementID, categoryID); err != nil {
+					return err
+				}
+			}
+			if err := moveBudgets(ctx, tx, categoryID, replacementID); err != nil {
+				return err
+			}
+		}
+
+		result, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, categoryID)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// Totals sums the user's transactions per category for the given filter and
+// rolls the sums of sub-categories up into their parents. Only categories
+// visible to the user are returned, as a tree.
+func (c *CategoryStore) Totals(ctx context.Context, userID int64, filter ListTransactionsByUserFilter) ([]*CategoryTotal, error) {
+	categories, err := c.ListCategories(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT t.category_id, COALESCE(SUM(t.amount), 0)
+		FROM individual_transactions t
+		WHERE t.user_id = $1
+	`
+	b := newQueryBuilder(userID)
+	filter.apply(b)
+	query += b.and() + " GROUP BY t.category_id"
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := c.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	amounts := make(map[int64]float64)
+	for rows.Next() {
+		var categoryID int64
+		var amount float64
+		if err := rows.Scan(&categoryID, &amount); err != nil {
+			return nil, err
+		}
+		amounts[categoryID] = amount
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return RollUpCategoryTotals(categories, amounts), nil
+}
+
+// BuildCategoryTree nests categories under their parents. Categories whose
+// parent is not in the list are returned as roots.
+func BuildCategoryTree(categories []*Category) []*Category {
+	byID := make(map[int64]*Category, len(categories))
+	for _, category := range categories {
+		category.Children = nil
+		byID[category.ID] = category
+	}
+
+	roots := []*Category{}
+	for _, category := range categories {
+		if category.ParentID != nil {
+			if parent, ok := byID[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, category)
+				continue
+			}
+		}
+		roots = append(roots, category)
+	}
+	return roots
+}
+
+// RollUpCategoryTotals builds the category tree with the amount of every
+// category and the total including all of its descendants.
+func RollUpCategoryTotals(categories []*Category, amounts map[int64]float64) []*CategoryTotal {
+	var build func(category *Category) *CategoryTotal
+	build = func(category *Category) *CategoryTotal {
+		node := &CategoryTotal{
+			CategoryID: category.ID,
+			Name:       category.Name,
+			Type:       category.Type,
+			ParentID:   category.ParentID,
+			Amount:     amounts[category.ID],
+		}
+		node.Total = node.Amount
+		for _, child := range category.Children {
+			childNode := build(child)
+			node.Total += childNode.Total
+			node.Children = append(node.Children, childNode)
+		}
+		return node
+	}
+
+	totals := []*CategoryTotal{}
+	for _, root := range BuildCategoryTree(categories) {
+		totals = append(totals, build(root))
+	}
+	return totals
+}
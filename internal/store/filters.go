@@ -0,0 +1,45 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// queryBuilder collects SQL conditions and their arguments, numbering the
+// placeholders as they are added so optional filters can be combined freely.
+type queryBuilder struct {
+	conditions []string
+	args       []any
+}
+
+func newQueryBuilder(args ...any) *queryBuilder {
+	return &queryBuilder{args: args}
+}
+
+// arg registers a query argument and returns its placeholder.
+func (b *queryBuilder) arg(value any) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+// where adds a condition in which every "?" is replaced by the placeholder of
+// the matching argument.
+func (b *queryBuilder) where(condition string, args ...any) {
+	for _, value := range args {
+		condition = strings.Replace(condition, "?", b.arg(value), 1)
+	}
+	b.conditions = append(b.conditions, condition)
+}
+
+// and renders the collected conditions to be appended to an existing WHERE clause.
+func (b *queryBuilder) and() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " AND " + strings.Join(b.conditions, " AND ")
+}
+
+// escapeLike escapes the LIKE wildcards in a user supplied search term.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
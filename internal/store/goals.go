@@ -0,0 +1,332 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var ErrDuplicateGoalContribution = errors.New("the transaction is already tagged with this goal")
+
+// Sources of goal contributions.
+const (
+	ContributionSourceDeposit     = "deposit"
+	ContributionSourceTransaction = "transaction"
+	ContributionSourceCategory    = "category"
+)
+
+// Goal is a savings target. Contributions are explicit deposits, transactions
+// tagged with the goal and, if the goal is linked to a category, every
+// transaction of that category and its sub-categories. TargetDate is formatted
+// as YYYY-MM-DD.
+type Goal struct {
+	ID           int64   `json:"id"`
+	UserID       int64   `json:"userId"`
+	Name         string  `json:"name"`
+	TargetAmount float64 `json:"targetAmount"`
+	TargetDate   string  `json:"targetDate"`
+	CategoryID   *int64  `json:"categoryId"`
+	CategoryName string  `json:"categoryName,omitempty"`
+	CreatedAt    string  `json:"createdAt"`
+	UpdatedAt    string  `json:"updatedAt"`
+}
+
+// GoalContribution is money put towards a goal. ID is the id of the deposit or
+// tag and is 0 for transactions that count through the linked category.
+type GoalContribution struct {
+	ID            int64   `json:"id"`
+	GoalID        int64   `json:"goalId"`
+	TransactionID *int64  `json:"transactionId,omitempty"`
+	Source        string  `json:"source"`
+	Amount        float64 `json:"amount"`
+	Date          string  `json:"date"`
+	Note          string  `json:"note,omitempty"`
+}
+
+// GoalSavings sums up the contributions of a goal.
+type GoalSavings struct {
+	Saved             float64
+	Contributions     int
+	FirstContribution *string // YYYY-MM-DD
+}
+
+type GoalStore struct {
+	db *sql.DB
+}
+
+func (s *GoalStore) Create(ctx context.Context, goal *Goal) error {
+	query := `
+		INSERT INTO goals (user_id, name, target_amount, target_date, category_id)
+		VALUES ($1, $2, $3, to_date($4, 'YYYY-MM-DD'), $5)
+		RETURNING id, created_at, updated_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, goal.UserID, goal.Name, goal.TargetAmount, goal.TargetDate, goal.CategoryID).
+		Scan(&goal.ID, &goal.CreatedAt, &goal.UpdatedAt)
+}
+
+const goalColumns = `
+	g.id, g.user_id, g.name, g.target_amount, to_char(g.target_date, 'YYYY-MM-DD'), g.category_id, COALESCE(c.name, ''),
+	g.created_at, g.updated_at
+`
+
+func scanGoal(row rowScanner, goal *Goal) error {
+	var categoryID sql.NullInt64
+	err := row.Scan(
+		&goal.ID,
+		&goal.UserID,
+		&goal.Name,
+		&goal.TargetAmount,
+		&goal.TargetDate,
+		&categoryID,
+		&goal.CategoryName,
+		&goal.CreatedAt,
+		&goal.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	goal.CategoryID = nil
+	if categoryID.Valid {
+		goal.CategoryID = &categoryID.Int64
+	}
+	return nil
+}
+
+// ListByUser returns the user's goals, nearest target date first.
+func (s *GoalStore) ListByUser(ctx context.Context, userID int64) ([]Goal, error) {
+	query := `
+		SELECT ` + goalColumns + `
+		FROM goals g
+		LEFT JOIN categories c ON c.id = g.category_id
+		WHERE g.user_id = $1
+		ORDER BY g.target_date, g.id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	goals := []Goal{}
+	for rows.Next() {
+		var goal Goal
+		if err := scanGoal(rows, &goal); err != nil {
+			return nil, err
+		}
+		goals = append(goals, goal)
+	}
+	return goals, rows.Err()
+}
+
+func (s *GoalStore) GetByID(ctx context.Context, id int64) (*Goal, error) {
+	query := `
+		SELECT ` + goalColumns + `
+		FROM goals g
+		LEFT JOIN categories c ON c.id = g.category_id
+		WHERE g.id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	goal := &Goal{}
+	if err := scanGoal(s.db.QueryRowContext(ctx, query, id), goal); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return goal, nil
+}
+
+func (s *GoalStore) Update(ctx context.Context, goal *Goal) error {
+	query := `
+		UPDATE goals
+		SET name = $1, target_amount = $2, target_date = to_date($3, 'YYYY-MM-DD'), category_id = $4, updated_at = NOW()
+		WHERE id = $5
+		RETURNING updated_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, goal.Name, goal.TargetAmount, goal.TargetDate, goal.CategoryID, goal.ID).
+		Scan(&goal.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *GoalStore) Delete(ctx context.Context, id int64) error {
+	query := `DELETE FROM goals WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AddContribution records a deposit, or tags a transaction with the goal when
+// TransactionID is set.
+func (s *GoalStore) AddContribution(ctx context.Context, contribution *GoalContribution) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if contribution.TransactionID != nil {
+		query := `
+			INSERT INTO goal_contributions (goal_id, transaction_id, note)
+			VALUES ($1, $2, NULLIF($3, ''))
+			RETURNING id
+		`
+		err := s.db.QueryRowContext(ctx, query, contribution.GoalID, *contribution.TransactionID, contribution.Note).
+			Scan(&contribution.ID)
+		if err != nil {
+			switch {
+			case err.Error() == `pq: duplicate key value violates unique constraint "goal_contributions_goal_id_transaction_id_key"`:
+				return ErrDuplicateGoalContribution
+			default:
+				return err
+			}
+		}
+		contribution.Source = ContributionSourceTransaction
+		return nil
+	}
+
+	query := `
+		INSERT INTO goal_contributions (goal_id, amount, contribution_date, note)
+		VALUES ($1, $2, to_date($3, 'YYYY-MM-DD'), NULLIF($4, ''))
+		RETURNING id
+	`
+	contribution.Source = ContributionSourceDeposit
+	return s.db.QueryRowContext(ctx, query, contribution.GoalID, contribution.Amount, contribution.Date, contribution.Note).
+		Scan(&contribution.ID)
+}
+
+// DeleteContribution removes a deposit or untags a transaction.
+func (s *GoalStore) DeleteContribution(ctx context.Context, goalID, contributionID int64) error {
+	query := `DELETE FROM goal_contributions WHERE id = $1 AND goal_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, contributionID, goalID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// goalContributions selects every contribution of the goal $1: deposits, tagged
+// transactions and the transactions of the linked category tree. A tagged
+// transaction of the linked category is counted once.
+const goalContributions = `
+	WITH RECURSIVE goal AS (
+		SELECT id, user_id, category_id FROM goals WHERE id = $1
+	),
+	tree AS (
+		SELECT c.id FROM categories c JOIN goal ON c.id = goal.category_id
+		UNION
+		SELECT c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+	),
+	contributions AS (
+		SELECT gc.id, NULL::bigint AS transaction_id, '` + ContributionSourceDeposit + `' AS source, gc.amount,
+			gc.contribution_date AS contribution_date, COALESCE(gc.note, '') AS note
+		FROM goal_contributions gc
+		WHERE gc.goal_id = $1 AND gc.transaction_id IS NULL
+		UNION ALL
+		SELECT COALESCE(gc.id, 0), t.id,
+			CASE WHEN gc.id IS NULL THEN '` + ContributionSourceCategory + `' ELSE '` + ContributionSourceTransaction + `' END,
+			t.amount, t.transaction_date::date, COALESCE(gc.note, t.description, '')
+		FROM individual_transactions t
+		JOIN goal ON goal.user_id = t.user_id
+		LEFT JOIN goal_contributions gc ON gc.goal_id = $1 AND gc.transaction_id = t.id
+		WHERE gc.id IS NOT NULL OR t.category_id IN (SELECT id FROM tree)
+	)
+`
+
+// ListContributions returns the contributions of a goal, oldest first.
+func (s *GoalStore) ListContributions(ctx context.Context, goalID int64) ([]GoalContribution, error) {
+	query := goalContributions + `
+		SELECT id, transaction_id, source, amount, to_char(contribution_date, 'YYYY-MM-DD'), note
+		FROM contributions
+		ORDER BY contribution_date, id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, goalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contributions := []GoalContribution{}
+	for rows.Next() {
+		contribution := GoalContribution{GoalID: goalID}
+		var transactionID sql.NullInt64
+		err := rows.Scan(
+			&contribution.ID,
+			&transactionID,
+			&contribution.Source,
+			&contribution.Amount,
+			&contribution.Date,
+			&contribution.Note,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if transactionID.Valid {
+			contribution.TransactionID = &transactionID.Int64
+		}
+		contributions = append(contributions, contribution)
+	}
+	return contributions, rows.Err()
+}
+
+// Savings sums up the contributions of a goal.
+func (s *GoalStore) Savings(ctx context.Context, goalID int64) (*GoalSavings, error) {
+	query := goalContributions + `
+		SELECT COALESCE(SUM(amount), 0), COUNT(*), to_char(MIN(contribution_date), 'YYYY-MM-DD')
+		FROM contributions
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	savings := &GoalSavings{}
+	var first sql.NullString
+	if err := s.db.QueryRowContext(ctx, query, goalID).Scan(&savings.Saved, &savings.Contributions, &first); err != nil {
+		return nil, err
+	}
+	if first.Valid {
+		savings.FirstContribution = &first.String
+	}
+	return savings, nil
+}
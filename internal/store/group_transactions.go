@@ -0,0 +1,296 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+const (
+	SplitEqual      = "equal"
+	SplitExact      = "exact"
+	SplitPercentage = "percentage"
+	SplitShares     = "shares"
+
+	// TransactionTypeSettlement marks a group transaction that records one member
+	// paying another back. Settlements have no category.
+	TransactionTypeSettlement = "settlement"
+)
+
+type GroupTransaction struct {
+	ID              int64                   `json:"id"`
+	GroupID         int64                   `json:"groupId"`
+	UserID          int64                   `json:"userId"`
+	CreatedBy       string                  `json:"createdBy"`
+	Amount          float64                 `json:"amount"`
+	CategoryName    string                  `json:"categoryName"`
+	CategoryID      int64                   `json:"categoryId"`
+	TransactionType string                  `json:"transactionType"`
+	TransactionDate string                  `json:"transactionDate"`
+	Description     string                  `json:"description"`
+	SplitType       string                  `json:"splitType"`
+	Splits          []GroupTransactionSplit `json:"splits"`
+	CreatedAt       string                  `json:"createdAt"`
+	UpdatedAt       string                  `json:"updatedAt"`
+}
+
+// GroupTransactionSplit is the part of a group transaction owed by one member.
+// Share holds the percentage or share count the amount was derived from.
+type GroupTransactionSplit struct {
+	UserID   int64    `json:"userId"`
+	Username string   `json:"username"`
+	Amount   float64  `json:"amount"`
+	Share    *float64 `json:"share,omitempty"`
+}
+
+type GroupTransactionStore struct {
+	db *sql.DB
+}
+
+// Create inserts the transaction together with its splits.
+func (t *GroupTransactionStore) Create(ctx context.Context, transaction *GroupTransaction) (*GroupTransaction, error) {
+	err := withTx(t.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO group_transactions (group_id, user_id, amount, category_id, transaction_type, description, transaction_date, split_type)
+			VALUES ($1, $2, $3, NULLIF($4::bigint, 0), $5, $6, $7, $8)
+			RETURNING id, created_at, updated_at, transaction_date
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query,
+			transaction.GroupID,
+			transaction.UserID,
+			transaction.Amount,
+			transaction.CategoryID,
+			transaction.TransactionType,
+			transaction.Description,
+			transaction.TransactionDate,
+			transaction.SplitType,
+		).Scan(&transaction.ID, &transaction.CreatedAt, &transaction.UpdatedAt, &transaction.TransactionDate)
+		if err != nil {
+			return err
+		}
+
+		return t.replaceSplits(ctx, tx, transaction.ID, transaction.Splits)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+func (t *GroupTransactionStore) ListByGroup(ctx context.Context, groupID int64) ([]GroupTransaction, error) {
+	query := `
+		SELECT t.id, t.group_id, t.user_id, u.username, t.amount, COALESCE(t.category_id, 0), COALESCE(c.name, ''), t.transaction_type,
+			t.description, t.split_type, t.created_at, t.updated_at, t.transaction_date
+		FROM group_transactions t
+		LEFT JOIN categories c ON t.category_id = c.id
+		JOIN users u ON t.user_id = u.id
+		WHERE t.group_id = $1
+		ORDER BY t.transaction_date DESC, t.id DESC
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := t.db.QueryContext(ctx, query, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []GroupTransaction{}
+	for rows.Next() {
+		var transaction GroupTransaction
+		if err := scanGroupTransaction(rows, &transaction); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := t.loadSplits(ctx, transactions); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+func (t *GroupTransactionStore) GetByID(ctx context.Context, transactionID int64) (*GroupTransaction, error) {
+	query := `
+		SELECT t.id, t.group_id, t.user_id, u.username, t.amount, COALESCE(t.category_id, 0), COALESCE(c.name, ''), t.transaction_type,
+			t.description, t.split_type, t.created_at, t.updated_at, t.transaction_date
+		FROM group_transactions t
+		LEFT JOIN categories c ON t.category_id = c.id
+		JOIN users u ON t.user_id = u.id
+		WHERE t.id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	transaction := GroupTransaction{}
+	err := scanGroupTransaction(t.db.QueryRowContext(ctx, query, transactionID), &transaction)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	transactions := []GroupTransaction{transaction}
+	if err := t.loadSplits(ctx, transactions); err != nil {
+		return nil, err
+	}
+	return &transactions[0], nil
+}
+
+// Update saves the transaction and replaces its splits.
+func (t *GroupTransactionStore) Update(ctx context.Context, transaction *GroupTransaction) error {
+	return withTx(t.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE group_transactions
+			SET amount = $1, category_id = NULLIF($2::bigint, 0), transaction_type = $3, description = $4, updated_at = NOW(), transaction_date = $5, split_type = $6
+			WHERE id = $7 AND group_id = $8
+			RETURNING updated_at, transaction_date
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query,
+			transaction.Amount,
+			transaction.CategoryID,
+			transaction.TransactionType,
+			transaction.Description,
+			transaction.TransactionDate,
+			transaction.SplitType,
+			transaction.ID,
+			transaction.GroupID,
+		).Scan(&transaction.UpdatedAt, &transaction.TransactionDate)
+		if err != nil {
+			switch {
+			case err == sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		return t.replaceSplits(ctx, tx, transaction.ID, transaction.Splits)
+	})
+}
+
+func (t *GroupTransactionStore) DeleteByID(ctx context.Context, transactionID int64) error {
+	query := `DELETE FROM group_transactions WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := t.db.ExecContext(ctx, query, transactionID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (t *GroupTransactionStore) replaceSplits(ctx context.Context, tx *sql.Tx, transactionID int64, splits []GroupTransactionSplit) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM group_transaction_splits WHERE transaction_id = $1`, transactionID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO group_transaction_splits (transaction_id, user_id, amount, share)
+		VALUES ($1, $2, $3, $4)
+	`
+	for _, split := range splits {
+		if _, err := tx.ExecContext(ctx, query, transactionID, split.UserID, split.Amount, split.Share); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSplits fills in the splits of the given transactions with a single query.
+func (t *GroupTransactionStore) loadSplits(ctx context.Context, transactions []GroupTransaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(transactions))
+	index := make(map[int64]int, len(transactions))
+	for i := range transactions {
+		ids[i] = transactions[i].ID
+		index[transactions[i].ID] = i
+		transactions[i].Splits = []GroupTransactionSplit{}
+	}
+
+	query := `
+		SELECT s.transaction_id, s.user_id, u.username, s.amount, s.share
+		FROM group_transaction_splits s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.transaction_id = ANY($1)
+		ORDER BY s.transaction_id, u.username
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := t.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transactionID int64
+		var share sql.NullFloat64
+		var split GroupTransactionSplit
+		if err := rows.Scan(&transactionID, &split.UserID, &split.Username, &split.Amount, &share); err != nil {
+			return err
+		}
+		if share.Valid {
+			split.Share = &share.Float64
+		}
+		i := index[transactionID]
+		transactions[i].Splits = append(transactions[i].Splits, split)
+	}
+	return rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanGroupTransaction(row rowScanner, transaction *GroupTransaction) error {
+	var description sql.NullString
+	err := row.Scan(
+		&transaction.ID,
+		&transaction.GroupID,
+		&transaction.UserID,
+		&transaction.CreatedBy,
+		&transaction.Amount,
+		&transaction.CategoryID,
+		&transaction.CategoryName,
+		&transaction.TransactionType,
+		&description,
+		&transaction.SplitType,
+		&transaction.CreatedAt,
+		&transaction.UpdatedAt,
+		&transaction.TransactionDate,
+	)
+	transaction.Description = description.String
+	return err
+}
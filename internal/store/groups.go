@@ -0,0 +1,248 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var (
+	ErrAlreadyGroupMember = errors.New("user is already a member of this group")
+	ErrNotGroupMember     = errors.New("user is not a member of this group")
+)
+
+type Group struct {
+	ID          int64         `json:"id"`
+	Name        string        `json:"name"`
+	CreatedBy   int64         `json:"createdBy"`
+	CreatedAt   string        `json:"createdAt"`
+	MemberCount int64         `json:"memberCount"`
+	Members     []GroupMember `json:"members,omitempty"`
+}
+
+type GroupMember struct {
+	UserID   int64  `json:"userId"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+type GroupStore struct {
+	db *sql.DB
+}
+
+// Create inserts the group and adds its creator plus the given users as members.
+func (g *GroupStore) Create(ctx context.Context, group *Group, memberIDs []int64) error {
+	return withTx(g.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO groups (name, created_by)
+			VALUES ($1, $2)
+			RETURNING id, created_at
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, group.Name, group.CreatedBy).Scan(&group.ID, &group.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		memberIDs = append([]int64{group.CreatedBy}, memberIDs...)
+		for _, userID := range memberIDs {
+			if err := g.addMember(ctx, tx, group.ID, userID); err != nil && err != ErrAlreadyGroupMember {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (g *GroupStore) ListByUser(ctx context.Context, userID int64) ([]Group, error) {
+	query := `
+		SELECT g.id, g.name, g.created_by, g.created_at,
+			(SELECT COUNT(*) FROM group_members m WHERE m.group_id = g.id) AS member_count
+		FROM groups g
+		JOIN group_members gm ON gm.group_id = g.id
+		WHERE gm.user_id = $1
+		ORDER BY g.created_at DESC, g.id DESC
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := g.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []Group{}
+	for rows.Next() {
+		var group Group
+		if err := rows.Scan(&group.ID, &group.Name, &group.CreatedBy, &group.CreatedAt, &group.MemberCount); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+func (g *GroupStore) GetByID(ctx context.Context, groupID int64) (*Group, error) {
+	query := `
+		SELECT id, name, created_by, created_at
+		FROM groups
+		WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	group := &Group{}
+	err := g.db.QueryRowContext(ctx, query, groupID).Scan(&group.ID, &group.Name, &group.CreatedBy, &group.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	members, err := g.GetMembers(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	group.Members = members
+	group.MemberCount = int64(len(members))
+
+	return group, nil
+}
+
+func (g *GroupStore) GetMembers(ctx context.Context, groupID int64) ([]GroupMember, error) {
+	query := `
+		SELECT u.id, u.username, u.email
+		FROM group_members gm
+		JOIN users u ON u.id = gm.user_id
+		WHERE gm.group_id = $1
+		ORDER BY u.username
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := g.db.QueryContext(ctx, query, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []GroupMember{}
+	for rows.Next() {
+		var member GroupMember
+		if err := rows.Scan(&member.UserID, &member.Username, &member.Email); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+func (g *GroupStore) IsMember(ctx context.Context, groupID, userID int64) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM group_members WHERE group_id = $1 AND user_id = $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var exists bool
+	if err := g.db.QueryRowContext(ctx, query, groupID, userID).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+func (g *GroupStore) Update(ctx context.Context, group *Group) error {
+	query := `UPDATE groups SET name = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := g.db.ExecContext(ctx, query, group.Name, group.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (g *GroupStore) Delete(ctx context.Context, groupID int64) error {
+	query := `DELETE FROM groups WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := g.db.ExecContext(ctx, query, groupID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (g *GroupStore) AddMember(ctx context.Context, groupID, userID int64) error {
+	return withTx(g.db, ctx, func(tx *sql.Tx) error {
+		return g.addMember(ctx, tx, groupID, userID)
+	})
+}
+
+func (g *GroupStore) addMember(ctx context.Context, tx *sql.Tx, groupID, userID int64) error {
+	query := `
+		INSERT INTO group_members (group_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (group_id, user_id) DO NOTHING
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := tx.ExecContext(ctx, query, groupID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrAlreadyGroupMember
+	}
+	return nil
+}
+
+func (g *GroupStore) RemoveMember(ctx context.Context, groupID, userID int64) error {
+	query := `DELETE FROM group_members WHERE group_id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := g.db.ExecContext(ctx, query, groupID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotGroupMember
+	}
+	return nil
+}
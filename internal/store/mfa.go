@@ -0,0 +1,279 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrTOTPEnabled = errors.New("two-factor authentication is already enabled")
+
+// TOTP is a user's TOTP secret. It only guards logins once EnabledAt is set,
+// which happens when the first code generated from it is verified.
+type TOTP struct {
+	UserID       int64
+	Secret       string
+	LastUsedStep *int64
+	CreatedAt    time.Time
+	EnabledAt    *time.Time
+}
+
+func (t *TOTP) Enabled() bool {
+	return t.EnabledAt != nil
+}
+
+type MFAStore struct {
+	db *sql.DB
+}
+
+// GetTOTP returns the user's TOTP secret, pending or enabled.
+func (s *MFAStore) GetTOTP(ctx context.Context, userID int64) (*TOTP, error) {
+	query := `
+		SELECT user_id, secret, last_used_step, created_at, enabled_at
+		FROM user_totp
+		WHERE user_id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	totp := &TOTP{}
+	var lastUsedStep sql.NullInt64
+	var enabledAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(
+		&totp.UserID,
+		&totp.Secret,
+		&lastUsedStep,
+		&totp.CreatedAt,
+		&enabledAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if lastUsedStep.Valid {
+		totp.LastUsedStep = &lastUsedStep.Int64
+	}
+	if enabledAt.Valid {
+		totp.EnabledAt = &enabledAt.Time
+	}
+	return totp, nil
+}
+
+// SetPendingTOTP stores a new secret for the user to verify, replacing one that
+// was never verified. It returns ErrTOTPEnabled when the user already has TOTP
+// enabled.
+func (s *MFAStore) SetPendingTOTP(ctx context.Context, userID int64, secret string) error {
+	query := `
+		INSERT INTO user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = NULL, created_at = NOW()
+		WHERE user_totp.enabled_at IS NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrTOTPEnabled
+	}
+	return nil
+}
+
+// EnableTOTP turns on the user's pending secret after a code of the given step
+// was verified and replaces the user's recovery codes with codeHashes.
+func (s *MFAStore) EnableTOTP(ctx context.Context, userID int64, step int64, codeHashes []string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE user_totp SET enabled_at = NOW(), last_used_step = $2
+			WHERE user_id = $1 AND enabled_at IS NULL
+		`, userID, step)
+		if err != nil {
+			return err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrTOTPEnabled
+		}
+		return replaceRecoveryCodes(ctx, tx, userID, codeHashes)
+	})
+}
+
+// UseTOTPStep records that a code of the given step was used. It returns false
+// when a code of this or a later step was used before, i.e. the code is
+// replayed.
+func (s *MFAStore) UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	query := `
+		UPDATE user_totp SET last_used_step = $2
+		WHERE user_id = $1 AND (last_used_step IS NULL OR last_used_step < $2)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+// UseRecoveryCode spends one of the user's recovery codes. It returns false
+// when the code does not exist or was used before.
+func (s *MFAStore) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+// RemainingRecoveryCodes counts the user's unused recovery codes.
+func (s *MFAStore) RemainingRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	query := `SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var remaining int
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&remaining)
+	return remaining, err
+}
+
+// DisableTOTP removes the user's secret and recovery codes.
+func (s *MFAStore) DisableTOTP(ctx context.Context, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(ctx, tx, userID, nil)
+	})
+}
+
+// RecordAttempt counts an attempt at the user's second factor. Attempts are
+// counted before the factor is checked, so parallel guesses count as well, and
+// ResetAttempts clears the count once one succeeds. The attempt that reaches
+// maxAttempts locks the second factor for lockout. While it is locked nothing
+// is recorded and RecordAttempt returns when the lock ends.
+func (s *MFAStore) RecordAttempt(ctx context.Context, userID int64, maxAttempts int, lockout time.Duration) (*time.Time, error) {
+	query := `
+		UPDATE user_totp
+		SET failed_attempts = CASE WHEN locked_until IS NULL THEN failed_attempts + 1 ELSE 1 END,
+			locked_until = CASE
+				WHEN locked_until IS NULL AND failed_attempts + 1 >= $2 THEN NOW() + make_interval(secs => $3)
+			END
+		WHERE user_id = $1 AND (locked_until IS NULL OR locked_until <= NOW())
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, maxAttempts, lockout.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rows > 0 {
+		return nil, nil
+	}
+
+	var lockedUntil time.Time
+	err = s.db.QueryRowContext(ctx, `SELECT locked_until FROM user_totp WHERE user_id = $1`, userID).Scan(&lockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &lockedUntil, nil
+}
+
+// ResetAttempts clears the attempts counted by RecordAttempt after the second
+// factor was passed.
+func (s *MFAStore) ResetAttempts(ctx context.Context, userID int64) error {
+	query := `UPDATE user_totp SET failed_attempts = 0, locked_until = NULL WHERE user_id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// CreateChallenge stores the jti of a login's challenge token, dropping the
+// user's expired ones.
+func (s *MFAStore) CreateChallenge(ctx context.Context, id string, userID int64, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM mfa_challenges WHERE user_id = $1 AND expires_at <= NOW()`, userID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO mfa_challenges (id, user_id, expires_at) VALUES ($1, $2, $3)
+		`, id, userID, expiresAt)
+		return err
+	})
+}
+
+// UseChallenge spends the user's challenge with the given jti. It returns
+// false when the challenge does not exist, expired or was used before.
+func (s *MFAStore) UseChallenge(ctx context.Context, id string, userID int64) (bool, error) {
+	query := `
+		UPDATE mfa_challenges SET used_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND used_at IS NULL AND expires_at > NOW()
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, codeHash := range codeHashes {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)
+		`, userID, codeHash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PersonalAccessToken lets scripts call the API as a user, limited to its
+// scopes. Only the sha256 hash of the token is stored.
+type PersonalAccessToken struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"userId"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	TokenHash  string     `json:"-"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type PersonalAccessTokenStore struct {
+	db *sql.DB
+}
+
+func (s *PersonalAccessTokenStore) Create(ctx context.Context, token *PersonalAccessToken) error {
+	query := `
+		INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query,
+		token.UserID,
+		token.Name,
+		token.TokenHash,
+		pq.Array(token.Scopes),
+		token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+}
+
+// ListByUser returns the user's tokens, expired ones included, newest first.
+func (s *PersonalAccessTokenStore) ListByUser(ctx context.Context, userID int64) ([]PersonalAccessToken, error) {
+	query := `
+		SELECT id, user_id, name, scopes, expires_at, last_used_at, created_at
+		FROM personal_access_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []PersonalAccessToken{}
+	for rows.Next() {
+		var token PersonalAccessToken
+		if err := scanPersonalAccessToken(rows, &token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// Use looks up the unexpired token stored under tokenHash and records that it
+// was used.
+func (s *PersonalAccessTokenStore) Use(ctx context.Context, tokenHash string) (*PersonalAccessToken, error) {
+	query := `
+		UPDATE personal_access_tokens SET last_used_at = NOW()
+		WHERE token_hash = $1 AND expires_at > NOW()
+		RETURNING id, user_id, name, scopes, expires_at, last_used_at, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	token := &PersonalAccessToken{}
+	if err := scanPersonalAccessToken(s.db.QueryRowContext(ctx, query, tokenHash), token); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+// Delete revokes one of the user's tokens.
+func (s *PersonalAccessTokenStore) Delete(ctx context.Context, userID, id int64) error {
+	query := `DELETE FROM personal_access_tokens WHERE id = $1 AND user_id = $2`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanPersonalAccessToken(row rowScanner, token *PersonalAccessToken) error {
+	var lastUsedAt sql.NullTime
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		pq.Array(&token.Scopes),
+		&token.ExpiresAt,
+		&lastUsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return nil
+}
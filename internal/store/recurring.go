@@ -0,0 +1,348 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+	FrequencyYearly  = "yearly"
+
+	// maxCatchUpOccurrences caps how many missed occurrences of one template are
+	// posted in a single run, so a long outage is caught up over several runs.
+	maxCatchUpOccurrences = 100
+)
+
+// RecurringTransaction is a template that is posted to individual_transactions
+// every Interval days, weeks, months or years from StartDate, until EndDate or
+// until OccurrenceLimit occurrences have been posted.
+type RecurringTransaction struct {
+	ID                int64      `json:"id"`
+	UserID            int64      `json:"userId"`
+	CategoryID        int64      `json:"categoryId"`
+	CategoryName      string     `json:"categoryName"`
+	Amount            float64    `json:"amount"`
+	TransactionType   string     `json:"transactionType"`
+	Description       string     `json:"description"`
+	Frequency         string     `json:"frequency"`
+	Interval          int        `json:"interval"`
+	StartDate         time.Time  `json:"startDate"`
+	EndDate           *time.Time `json:"endDate"`
+	OccurrenceLimit   *int       `json:"count"`
+	OccurrencesPosted int        `json:"occurrencesPosted"`
+	NextRunAt         *time.Time `json:"nextRunAt"`
+	CreatedAt         string     `json:"createdAt"`
+	UpdatedAt         string     `json:"updatedAt"`
+}
+
+// Occurrence returns the date of the n-th occurrence, counting from 0. Monthly and
+// yearly schedules keep the day of the start date, clamped to the end of shorter
+// months, so a schedule starting on the 31st never drifts.
+func (rt *RecurringTransaction) Occurrence(n int) time.Time {
+	step := n * rt.Interval
+	switch rt.Frequency {
+	case FrequencyDaily:
+		return rt.StartDate.AddDate(0, 0, step)
+	case FrequencyWeekly:
+		return rt.StartDate.AddDate(0, 0, 7*step)
+	case FrequencyYearly:
+		return addMonthsClamped(rt.StartDate, 12*step)
+	default:
+		return addMonthsClamped(rt.StartDate, step)
+	}
+}
+
+// NextOccurrence returns the next occurrence to post, or nil once the schedule has ended.
+func (rt *RecurringTransaction) NextOccurrence() *time.Time {
+	if rt.OccurrenceLimit != nil && rt.OccurrencesPosted >= *rt.OccurrenceLimit {
+		return nil
+	}
+	next := rt.Occurrence(rt.OccurrencesPosted)
+	if rt.EndDate != nil && next.After(*rt.EndDate) {
+		return nil
+	}
+	return &next
+}
+
+// PendingOccurrences returns the occurrences that have not been posted yet and
+// fall before end, in order.
+func (rt *RecurringTransaction) PendingOccurrences(end time.Time) []time.Time {
+	var dates []time.Time
+	for n := rt.OccurrencesPosted; rt.OccurrenceLimit == nil || n < *rt.OccurrenceLimit; n++ {
+		next := rt.Occurrence(n)
+		if !next.Before(end) || (rt.EndDate != nil && next.After(*rt.EndDate)) {
+			break
+		}
+		dates = append(dates, next)
+	}
+	return dates
+}
+
+func addMonthsClamped(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, lastDay)-1)
+}
+
+type RecurringTransactionStore struct {
+	db *sql.DB
+}
+
+func (s *RecurringTransactionStore) Create(ctx context.Context, rt *RecurringTransaction) error {
+	query := `
+		INSERT INTO recurring_transactions (user_id, category_id, amount, transaction_type, description, frequency,
+			repeat_interval, start_date, end_date, occurrence_limit, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, occurrences_posted, created_at, updated_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rt.NextRunAt = rt.NextOccurrence()
+	return s.db.QueryRowContext(ctx, query,
+		rt.UserID,
+		rt.CategoryID,
+		rt.Amount,
+		rt.TransactionType,
+		rt.Description,
+		rt.Frequency,
+		rt.Interval,
+		rt.StartDate,
+		rt.EndDate,
+		rt.OccurrenceLimit,
+		rt.NextRunAt,
+	).Scan(&rt.ID, &rt.OccurrencesPosted, &rt.CreatedAt, &rt.UpdatedAt)
+}
+
+const recurringTransactionColumns = `
+	r.id, r.user_id, r.category_id, c.name, r.amount, r.transaction_type, r.description, r.frequency,
+	r.repeat_interval, r.start_date, r.end_date, r.occurrence_limit, r.occurrences_posted, r.next_run_at,
+	r.created_at, r.updated_at
+`
+
+func scanRecurringTransaction(row rowScanner, rt *RecurringTransaction) error {
+	var description sql.NullString
+	var limit sql.NullInt64
+	err := row.Scan(
+		&rt.ID,
+		&rt.UserID,
+		&rt.CategoryID,
+		&rt.CategoryName,
+		&rt.Amount,
+		&rt.TransactionType,
+		&description,
+		&rt.Frequency,
+		&rt.Interval,
+		&rt.StartDate,
+		&rt.EndDate,
+		&limit,
+		&rt.OccurrencesPosted,
+		&rt.NextRunAt,
+		&rt.CreatedAt,
+		&rt.UpdatedAt,
+	)
+	rt.Description = description.String
+	if limit.Valid {
+		n := int(limit.Int64)
+		rt.OccurrenceLimit = &n
+	}
+	return err
+}
+
+func (s *RecurringTransactionStore) ListByUser(ctx context.Context, userID int64) ([]RecurringTransaction, error) {
+	query := `
+		SELECT ` + recurringTransactionColumns + `
+		FROM recurring_transactions r
+		JOIN categories c ON c.id = r.category_id
+		WHERE r.user_id = $1
+		ORDER BY r.next_run_at NULLS LAST, r.id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recurring := []RecurringTransaction{}
+	for rows.Next() {
+		var rt RecurringTransaction
+		if err := scanRecurringTransaction(rows, &rt); err != nil {
+			return nil, err
+		}
+		recurring = append(recurring, rt)
+	}
+	return recurring, rows.Err()
+}
+
+func (s *RecurringTransactionStore) GetByID(ctx context.Context, id int64) (*RecurringTransaction, error) {
+	query := `
+		SELECT ` + recurringTransactionColumns + `
+		FROM recurring_transactions r
+		JOIN categories c ON c.id = r.category_id
+		WHERE r.id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rt := &RecurringTransaction{}
+	if err := scanRecurringTransaction(s.db.QueryRowContext(ctx, query, id), rt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return rt, nil
+}
+
+// Update saves the template. The schedule itself (frequency, interval and start
+// date) cannot change, but the end date and count can, so the next run is
+// recomputed.
+func (s *RecurringTransactionStore) Update(ctx context.Context, rt *RecurringTransaction) error {
+	query := `
+		UPDATE recurring_transactions
+		SET category_id = $1, amount = $2, transaction_type = $3, description = $4, end_date = $5,
+			occurrence_limit = $6, next_run_at = $7, updated_at = NOW()
+		WHERE id = $8 AND user_id = $9
+		RETURNING updated_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rt.NextRunAt = rt.NextOccurrence()
+	err := s.db.QueryRowContext(ctx, query,
+		rt.CategoryID,
+		rt.Amount,
+		rt.TransactionType,
+		rt.Description,
+		rt.EndDate,
+		rt.OccurrenceLimit,
+		rt.NextRunAt,
+		rt.ID,
+		rt.UserID,
+	).Scan(&rt.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *RecurringTransactionStore) Delete(ctx context.Context, id int64) error {
+	query := `DELETE FROM recurring_transactions WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PostDue materialises every occurrence due at or before now into
+// individual_transactions and returns the transactions that were created.
+// Templates are locked while they are processed and every occurrence is
+// inserted at most once, so concurrent or restarted runs never double-post.
+func (s *RecurringTransactionStore) PostDue(ctx context.Context, now time.Time, batchSize int) ([]*Transaction, error) {
+	var posted []*Transaction
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			SELECT ` + recurringTransactionColumns + `
+			FROM recurring_transactions r
+			JOIN categories c ON c.id = r.category_id
+			WHERE r.next_run_at IS NOT NULL AND r.next_run_at <= $1
+			ORDER BY r.next_run_at
+			LIMIT $2
+			FOR UPDATE OF r SKIP LOCKED
+		`
+		rows, err := tx.QueryContext(ctx, query, now, batchSize)
+		if err != nil {
+			return err
+		}
+
+		var due []RecurringTransaction
+		for rows.Next() {
+			var rt RecurringTransaction
+			if err := scanRecurringTransaction(rows, &rt); err != nil {
+				rows.Close()
+				return err
+			}
+			due = append(due, rt)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		insert := `
+			INSERT INTO individual_transactions (user_id, amount, category_id, transaction_type, description, transaction_date, recurring_transaction_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (recurring_transaction_id, transaction_date) WHERE recurring_transaction_id IS NOT NULL DO NOTHING
+			RETURNING id, created_at, updated_at, transaction_date
+		`
+		advance := `
+			UPDATE recurring_transactions
+			SET occurrences_posted = $1, next_run_at = $2
+			WHERE id = $3
+		`
+		for _, rt := range due {
+			for i := 0; i < maxCatchUpOccurrences; i++ {
+				next := rt.NextOccurrence()
+				if next == nil || next.After(now) {
+					break
+				}
+
+				transaction := &Transaction{
+					UserID:          rt.UserID,
+					Amount:          rt.Amount,
+					CategoryID:      rt.CategoryID,
+					CategoryName:    rt.CategoryName,
+					TransactionType: rt.TransactionType,
+					Description:     rt.Description,
+				}
+				err := tx.QueryRowContext(ctx, insert, rt.UserID, rt.Amount, rt.CategoryID, rt.TransactionType, rt.Description, *next, rt.ID).Scan(
+					&transaction.ID,
+					&transaction.CreatedAt,
+					&transaction.UpdatedAt,
+					&transaction.TransactionDate,
+				)
+				switch {
+				case err == nil:
+					posted = append(posted, transaction)
+				case err != sql.ErrNoRows:
+					// sql.ErrNoRows means the occurrence was already posted
+					return err
+				}
+				rt.OccurrencesPosted++
+			}
+
+			if _, err := tx.ExecContext(ctx, advance, rt.OccurrencesPosted, rt.NextOccurrence(), rt.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return posted, nil
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrRefreshTokenExpired = errors.New("refresh token has expired")
+	ErrRefreshTokenRevoked = errors.New("refresh token has been revoked")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, its sessions have been revoked")
+)
+
+// RefreshToken is an opaque token that is exchanged for a new access token.
+// Only the sha256 hash of the token is stored. Tokens issued from one login
+// share a FamilyID, the ID of its session, so a leaked token can take down
+// everything derived from it.
+type RefreshToken struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"userId"`
+	FamilyID  string    `json:"familyId"`
+	TokenHash string    `json:"-"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+// Create stores a refresh token. FamilyID must be the ID of the session the
+// token belongs to.
+func (s *RefreshTokenStore) Create(ctx context.Context, token *RefreshToken) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return insertRefreshToken(ctx, s.db, token)
+}
+
+// Rotate exchanges the token stored under tokenHash for next, which joins the
+// same user and family, and extends the session to next's expiry. The old token
+// can not be used again: presenting a rotated token revokes the session with
+// its whole family and returns ErrRefreshTokenReused.
+func (s *RefreshTokenStore) Rotate(ctx context.Context, tokenHash string, next *RefreshToken) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var reused bool
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			SELECT id, user_id, family_id, expires_at, used_at, revoked_at
+			FROM refresh_tokens
+			WHERE token_hash = $1
+			FOR UPDATE
+		`
+		var current RefreshToken
+		var usedAt, revokedAt sql.NullTime
+		err := tx.QueryRowContext(ctx, query, tokenHash).Scan(
+			&current.ID,
+			&current.UserID,
+			&current.FamilyID,
+			&current.ExpiresAt,
+			&usedAt,
+			&revokedAt,
+		)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		switch {
+		case revokedAt.Valid:
+			return ErrRefreshTokenRevoked
+		case usedAt.Valid:
+			// the token was stolen or the client replayed it, either way
+			// nobody holding a token of this family can be trusted
+			reused = true
+			_, err := revokeSessions(ctx, tx, `id = $1`, current.FamilyID)
+			return err
+		case !current.ExpiresAt.After(time.Now()):
+			return ErrRefreshTokenExpired
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`, current.ID); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE sessions SET last_seen_at = NOW(), expires_at = $2 WHERE id = $1
+		`, current.FamilyID, next.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		next.UserID = current.UserID
+		next.FamilyID = current.FamilyID
+		return insertRefreshToken(ctx, tx, next)
+	})
+	if err == nil && reused {
+		return ErrRefreshTokenReused
+	}
+	return err
+}
+
+func insertRefreshToken(ctx context.Context, q querier, token *RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	return q.QueryRowContext(ctx, query,
+		token.UserID,
+		token.FamilyID,
+		token.TokenHash,
+		token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+}
@@ -5,6 +5,14 @@ import (
 	"database/sql"
 )
 
+// Levels of the roles seeded by migration 000008. A role grants everything the
+// roles with lower levels can do.
+const (
+	RoleLevelUser      = 1
+	RoleLevelModerator = 2
+	RoleLevelAdmin     = 3
+)
+
 type Role struct {
 	ID          int64  `json:"id"`
 	Name        string `json:"name"`
@@ -17,13 +25,45 @@ type RoleStore struct {
 }
 
 func (s *RoleStore) GetByName(ctx context.Context, slug string) (*Role, error) {
-	query := `SELECT id, name, description, level FROM roles WHERE name = $1`
-	
+	query := `SELECT id, name, COALESCE(description, ''), level FROM roles WHERE name = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
 	role := &Role{}
 	err := s.db.QueryRowContext(ctx, query, slug).Scan(&role.ID, &role.Name, &role.Description, &role.Level)
 	if err != nil {
-		return nil, err
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
 	}
 
 	return role, nil
-}
\ No newline at end of file
+}
+
+// List returns all roles, lowest level first.
+func (s *RoleStore) List(ctx context.Context) ([]Role, error) {
+	query := `SELECT id, name, COALESCE(description, ''), level FROM roles ORDER BY level, name`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []Role{}
+	for rows.Next() {
+		var role Role
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.Level); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
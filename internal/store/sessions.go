@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Session is a login. Its ID is the jti claim of the access tokens issued for
+// it and the family of its refresh tokens.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int64     `json:"userId"`
+	Device     string    `json:"device"`
+	IPAddress  string    `json:"ipAddress"`
+	UserAgent  string    `json:"userAgent"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+type SessionStore struct {
+	db *sql.DB
+}
+
+func (s *SessionStore) Create(ctx context.Context, session *Session) error {
+	query := `
+		INSERT INTO sessions (id, user_id, device, ip_address, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, last_seen_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query,
+		session.ID,
+		session.UserID,
+		session.Device,
+		session.IPAddress,
+		session.UserAgent,
+		session.ExpiresAt,
+	).Scan(&session.CreatedAt, &session.LastSeenAt)
+}
+
+// IsActive reports whether the session exists for the user and is neither
+// revoked nor expired.
+func (s *SessionStore) IsActive(ctx context.Context, id string, userID int64) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM sessions
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+		)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var active bool
+	err := s.db.QueryRowContext(ctx, query, id, userID).Scan(&active)
+	return active, err
+}
+
+// ListActive returns the user's sessions that are neither revoked nor expired,
+// most recently used first.
+func (s *SessionStore) ListActive(ctx context.Context, userID int64) ([]Session, error) {
+	query := `
+		SELECT id, user_id, device, ip_address, user_agent, created_at, last_seen_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_seen_at DESC, created_at DESC
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.Device,
+			&session.IPAddress,
+			&session.UserAgent,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+			&session.ExpiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// Revoke ends one of the user's sessions and its refresh tokens. It returns
+// ErrNotFound when the user has no such active session.
+func (s *SessionStore) Revoke(ctx context.Context, userID int64, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		revoked, err := revokeSessions(ctx, tx, `user_id = $1 AND id = $2`, userID, id)
+		if err != nil {
+			return err
+		}
+		if revoked == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// RevokeOthers ends every session of the user except keepID and returns how
+// many were ended.
+func (s *SessionStore) RevokeOthers(ctx context.Context, userID int64, keepID string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var revoked int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var err error
+		revoked, err = revokeSessions(ctx, tx, `user_id = $1 AND id <> $2`, userID, keepID)
+		return err
+	})
+	return revoked, err
+}
+
+// revokeSessions revokes the active sessions matching condition together with
+// their refresh tokens and returns how many sessions were revoked.
+func revokeSessions(ctx context.Context, tx *sql.Tx, condition string, args ...any) (int64, error) {
+	query := `
+		WITH revoked AS (
+			UPDATE sessions SET revoked_at = NOW()
+			WHERE ` + condition + ` AND revoked_at IS NULL AND expires_at > NOW()
+			RETURNING id
+		), tokens AS (
+			UPDATE refresh_tokens SET revoked_at = NOW()
+			WHERE family_id IN (SELECT id FROM revoked) AND revoked_at IS NULL
+		)
+		SELECT COUNT(*) FROM revoked
+	`
+	var revoked int64
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&revoked)
+	return revoked, err
+}
@@ -25,35 +25,155 @@ type Storage struct {
 		DeleteUserResetPasswordToken(ctx context.Context, token string) error
 		GetUserResetPasswordTokenCount(ctx context.Context, userID int64) (int64, error)
 		ResetPassword(ctx context.Context, token string, newPassword string) error
+		UpdateRole(ctx context.Context, userID, roleID int64) error
+	}
+	Roles interface {
+		GetByName(context.Context, string) (*Role, error)
+		List(context.Context) ([]Role, error)
 	}
 	Transactions interface {
 		Create(context.Context, *Transaction) (*Transaction, error)
+		CreateMany(context.Context, []*Transaction) ([]*Transaction, error)
+		ExistingExternalIDs(ctx context.Context, userID int64, externalIDs []string) (map[string]bool, error)
 		// add filtes for start date, end date, amount, transaction type in listtransactions
-		ListTransactionsByUser(context.Context, int64, ListTransactionsByUserFilter) ([]Transaction, error)
+		ListTransactionsByUser(context.Context, int64, ListTransactionsByUserFilter) (*TransactionPage, error)
+		Export(ctx context.Context, userID int64, filter ListTransactionsByUserFilter, fn func(*Transaction) error) error
+		DetectAnomaly(ctx context.Context, transaction *Transaction, rules AnomalyRules) error
+		ListAnomalies(ctx context.Context, userID int64, startDate, endDate string) ([]Transaction, error)
 		GetByID(context.Context, int64) (*Transaction, error)
 		Update(context.Context, *Transaction) error
 		DeleteByID(context.Context, int64) error
 	}
 	Category interface {
 		Create(context.Context, *Category) (*Category, error)
-		GetByName(context.Context, string) (*Category, error)
-		ListCategories(ctx context.Context) ([]*Category, error)
+		GetByID(context.Context, int64) (*Category, error)
+		GetByName(ctx context.Context, userID int64, name string) (*Category, error)
+		ListCategories(ctx context.Context, userID int64) ([]*Category, error)
+		Update(context.Context, *Category) error
+		Delete(ctx context.Context, categoryID, replacementID int64) error
+		Totals(ctx context.Context, userID int64, filter ListTransactionsByUserFilter) ([]*CategoryTotal, error)
 	}
 	Token interface {
 		Validate(token string) (bool, error)
 		ValidateResetPasswordToken(token string) (bool, error)
 	}
+	RefreshTokens interface {
+		Create(context.Context, *RefreshToken) error
+		Rotate(ctx context.Context, tokenHash string, next *RefreshToken) error
+	}
+	Sessions interface {
+		Create(context.Context, *Session) error
+		IsActive(ctx context.Context, id string, userID int64) (bool, error)
+		ListActive(ctx context.Context, userID int64) ([]Session, error)
+		Revoke(ctx context.Context, userID int64, id string) error
+		RevokeOthers(ctx context.Context, userID int64, keepID string) (int64, error)
+	}
+	MFA interface {
+		GetTOTP(ctx context.Context, userID int64) (*TOTP, error)
+		SetPendingTOTP(ctx context.Context, userID int64, secret string) error
+		EnableTOTP(ctx context.Context, userID int64, step int64, codeHashes []string) error
+		UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error)
+		UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error)
+		RemainingRecoveryCodes(ctx context.Context, userID int64) (int, error)
+		DisableTOTP(ctx context.Context, userID int64) error
+		RecordAttempt(ctx context.Context, userID int64, maxAttempts int, lockout time.Duration) (*time.Time, error)
+		ResetAttempts(ctx context.Context, userID int64) error
+		CreateChallenge(ctx context.Context, id string, userID int64, expiresAt time.Time) error
+		UseChallenge(ctx context.Context, id string, userID int64) (bool, error)
+	}
+	PersonalAccessTokens interface {
+		Create(context.Context, *PersonalAccessToken) error
+		ListByUser(context.Context, int64) ([]PersonalAccessToken, error)
+		Use(ctx context.Context, tokenHash string) (*PersonalAccessToken, error)
+		Delete(ctx context.Context, userID, id int64) error
+	}
+	Groups interface {
+		Create(ctx context.Context, group *Group, memberIDs []int64) error
+		ListByUser(context.Context, int64) ([]Group, error)
+		GetByID(context.Context, int64) (*Group, error)
+		GetMembers(context.Context, int64) ([]GroupMember, error)
+		IsMember(ctx context.Context, groupID, userID int64) (bool, error)
+		Update(context.Context, *Group) error
+		Delete(context.Context, int64) error
+		AddMember(ctx context.Context, groupID, userID int64) error
+		RemoveMember(ctx context.Context, groupID, userID int64) error
+	}
+	GroupTransactions interface {
+		Create(context.Context, *GroupTransaction) (*GroupTransaction, error)
+		ListByGroup(context.Context, int64) ([]GroupTransaction, error)
+		GetByID(context.Context, int64) (*GroupTransaction, error)
+		Update(context.Context, *GroupTransaction) error
+		DeleteByID(context.Context, int64) error
+	}
+	RecurringTransactions interface {
+		Create(context.Context, *RecurringTransaction) error
+		ListByUser(context.Context, int64) ([]RecurringTransaction, error)
+		GetByID(context.Context, int64) (*RecurringTransaction, error)
+		Update(context.Context, *RecurringTransaction) error
+		Delete(context.Context, int64) error
+		PostDue(ctx context.Context, now time.Time, batchSize int) ([]*Transaction, error)
+	}
+	Budgets interface {
+		Create(context.Context, *Budget) error
+		ListByUser(ctx context.Context, userID int64, month string) ([]Budget, error)
+		GetByID(context.Context, int64) (*Budget, error)
+		Update(context.Context, *Budget) error
+		Delete(context.Context, int64) error
+		StatusByMonth(ctx context.Context, userID int64, month string) ([]BudgetStatus, error)
+		StatusForCategory(ctx context.Context, userID, categoryID int64, month string) ([]BudgetStatus, error)
+		RecordAlert(ctx context.Context, budgetID int64, threshold int) (bool, error)
+		DeleteAlert(ctx context.Context, budgetID int64, threshold int) error
+		ListAllocations(ctx context.Context, budgetID int64) ([]BudgetAllocation, error)
+		RollOver(ctx context.Context, userID int64, month string) ([]BudgetAllocation, error)
+		Transfer(ctx context.Context, from, to *Budget, amount float64, note string) ([]BudgetAllocation, error)
+	}
+	Goals interface {
+		Create(context.Context, *Goal) error
+		ListByUser(context.Context, int64) ([]Goal, error)
+		GetByID(context.Context, int64) (*Goal, error)
+		Update(context.Context, *Goal) error
+		Delete(context.Context, int64) error
+		AddContribution(context.Context, *GoalContribution) error
+		DeleteContribution(ctx context.Context, goalID, contributionID int64) error
+		ListContributions(ctx context.Context, goalID int64) ([]GoalContribution, error)
+		Savings(ctx context.Context, goalID int64) (*GoalSavings, error)
+	}
+	Analytics interface {
+		Summary(ctx context.Context, scope AnalyticsScope, from, to time.Time) (*AnalyticsSummary, error)
+		Trend(ctx context.Context, scope AnalyticsScope, from, to time.Time) ([]MonthlyTrend, error)
+		ByCategory(ctx context.Context, scope AnalyticsScope, from, to time.Time) ([]CategoryAmount, error)
+		Daily(ctx context.Context, scope AnalyticsScope, month time.Time) ([]DailyAmount, error)
+		Balance(ctx context.Context, userID int64) (float64, error)
+		CategoryAverages(ctx context.Context, userID int64, from, to time.Time) ([]CategoryAverage, error)
+	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
 		Users:     &UserStore{db},
+		Roles:                 &RoleStore{db: db},
 		Transactions: &TransactionStore{db: db},
 		Category:  &CategoryStore{db: db},
 		Token:     &Token{db: db},
+		RefreshTokens:         &RefreshTokenStore{db: db},
+		Sessions:              &SessionStore{db: db},
+		MFA:                   &MFAStore{db: db},
+		PersonalAccessTokens:  &PersonalAccessTokenStore{db: db},
+		Groups:    &GroupStore{db: db},
+		GroupTransactions: &GroupTransactionStore{db: db},
+		RecurringTransactions: &RecurringTransactionStore{db: db},
+		Budgets:               &BudgetStore{db: db},
+		Goals:                 &GoalStore{db: db},
+		Analytics:             &AnalyticsStore{db: db},
 	}
 }
 
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 func withTx(db *sql.DB, ctx context.Context, fn func(*sql.Tx) error) error {
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
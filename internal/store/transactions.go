@@ -3,8 +3,32 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+const (
+	DefaultTransactionsPageSize = 50
+	MaxTransactionsPageSize     = 200
+
+	// exportFetchSize is how many rows an export fetches from its cursor at a time.
+	exportFetchSize = 500
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// transactionSortColumns maps the sort options accepted by the API to the column
+// used as the first keyset key. The row id is always the tie breaker.
+var transactionSortColumns = map[string]string{
+	"date":   "t.transaction_date",
+	"amount": "t.amount",
+}
+
 type Transaction struct {
 	ID              int64   `json:"id"`
 	UserID          int64   `json:"userId"`
@@ -14,8 +38,12 @@ type Transaction struct {
 	TransactionType string  `json:"transactionType"`
 	TransactionDate string  `json:"transactionDate"` // Assuming this is a string for simplicity, could be time.Time
 	Description     string  `json:"description"`
-	CreatedAt       string  `json:"createdAt"`
-	UpdatedAt       string  `json:"updatedAt"`
+	// ExternalID identifies a transaction imported from a bank statement.
+	ExternalID string `json:"externalId,omitempty"`
+	CreatedAt  string `json:"createdAt"`
+	UpdatedAt  string `json:"updatedAt"`
+	// Anomaly is set when the transaction was flagged as unusual for its category.
+	Anomaly *TransactionAnomaly `json:"anomaly"`
 }
 
 type TransactionStore struct {
@@ -46,74 +74,267 @@ func (t *TransactionStore) Create(ctx context.Context, transaction *Transaction)
 	return transaction, nil
 }
 
+// CreateMany inserts all transactions in a single database transaction, so
+// either every transaction is stored or none is. Transactions whose external id
+// is already stored for the user are skipped; the stored ones are returned.
+func (t *TransactionStore) CreateMany(ctx context.Context, transactions []*Transaction) ([]*Transaction, error) {
+	query := `
+		INSERT INTO individual_transactions (user_id, amount, category_id, transaction_type, description, transaction_date, external_id)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+		ON CONFLICT (user_id, external_id) WHERE external_id IS NOT NULL DO NOTHING
+		RETURNING id, created_at, updated_at, transaction_date
+	`
+	created := []*Transaction{}
+	err := withTx(t.db, ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, transaction := range transactions {
+			err := stmt.QueryRowContext(ctx,
+				transaction.UserID,
+				transaction.Amount,
+				transaction.CategoryID,
+				transaction.TransactionType,
+				transaction.Description,
+				transaction.TransactionDate,
+				transaction.ExternalID,
+			).Scan(&transaction.ID, &transaction.CreatedAt, &transaction.UpdatedAt, &transaction.TransactionDate)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			created = append(created, transaction)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// ExistingExternalIDs returns which of the given external ids are already
+// stored for the user.
+func (t *TransactionStore) ExistingExternalIDs(ctx context.Context, userID int64, externalIDs []string) (map[string]bool, error) {
+	query := `
+		SELECT external_id
+		FROM individual_transactions
+		WHERE user_id = $1 AND external_id = ANY($2)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := t.db.QueryContext(ctx, query, userID, pq.Array(externalIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var externalID string
+		if err := rows.Scan(&externalID); err != nil {
+			return nil, err
+		}
+		existing[externalID] = true
+	}
+	return existing, rows.Err()
+}
+
+// ListTransactionsByUserFilter narrows a user's transactions. Dates are inclusive
+// YYYY-MM-DD values matched against transaction_date.
 type ListTransactionsByUserFilter struct {
-	StartDate       string `json:"startDate"`
-	EndDate         string `json:"endDate"`
-	TransactionType string `json:"transactionType"`
+	StartDate       string   `json:"startDate"`
+	EndDate         string   `json:"endDate"`
+	TransactionType string   `json:"transactionType"`
+	CategoryIDs     []int64  `json:"categoryIds" validate:"omitempty,dive,gt=0"`
+	MinAmount       *float64 `json:"minAmount" validate:"omitempty,gte=0"`
+	MaxAmount       *float64 `json:"maxAmount" validate:"omitempty,gte=0"`
+	Search          string   `json:"search" validate:"omitempty,max=100"`
+	Limit           int      `json:"limit" validate:"omitempty,min=1,max=200"`
+	Cursor          string   `json:"cursor"`
+	SortBy          string   `json:"sortBy" validate:"omitempty,oneof=date amount"`
+	SortOrder       string   `json:"sortOrder" validate:"omitempty,oneof=asc desc"`
 }
+
+// apply adds the filter conditions for the individual_transactions table aliased as t.
+func (f ListTransactionsByUserFilter) apply(b *queryBuilder) {
+	if f.StartDate != "" {
+		b.where("t.transaction_date >= ?::date", f.StartDate)
+	}
+	if f.EndDate != "" {
+		b.where("t.transaction_date < ?::date + 1", f.EndDate)
+	}
+	if f.TransactionType != "" {
+		b.where("t.transaction_type = ?", f.TransactionType)
+	}
+	if len(f.CategoryIDs) > 0 {
+		// a parent category also matches the transactions of all its sub-categories
+		b.where(`t.category_id IN (
+			WITH RECURSIVE tree AS (
+				SELECT id FROM categories WHERE id = ANY(?)
+				UNION
+				SELECT c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+			)
+			SELECT id FROM tree
+		)`, pq.Array(f.CategoryIDs))
+	}
+	if f.MinAmount != nil {
+		b.where("t.amount >= ?", *f.MinAmount)
+	}
+	if f.MaxAmount != nil {
+		b.where("t.amount <= ?", *f.MaxAmount)
+	}
+	if f.Search != "" {
+		b.where("t.description ILIKE ?", "%"+escapeLike(f.Search)+"%")
+	}
+}
+
 type ListTransactionsResponse struct {
 	Transaction
 	CreatedAt string `json:"createdAt"`
 	CreatedBy string `json:"createdBy"`
 }
 
-func (t *TransactionStore) ListTransactionsByUser(ctx context.Context, userID int64, filter ListTransactionsByUserFilter) ([]Transaction, error) {
+// TransactionPage is one page of a keyset paginated transaction listing.
+// NextCursor is empty on the last page.
+type TransactionPage struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"next_cursor"`
+}
+
+// transactionCursor is the position after the last row of a page: the value of
+// the sort column and the row id, bound to the sort it was issued for.
+type transactionCursor struct {
+	SortBy    string `json:"s"`
+	SortOrder string `json:"o"`
+	Value     string `json:"v"`
+	ID        int64  `json:"id"`
+}
+
+func encodeTransactionCursor(c transactionCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeTransactionCursor(cursor, sortBy, sortOrder string) (*transactionCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &transactionCursor{}
+	if err := json.Unmarshal(data, c); err != nil || c.SortBy != sortBy || c.SortOrder != sortOrder || c.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func (t *TransactionStore) ListTransactionsByUser(ctx context.Context, userID int64, filter ListTransactionsByUserFilter) (*TransactionPage, error) {
 	// write a join query to get the transactions with category name
 	query := `
-		SELECT t.id, t.user_id, t.amount, c.name as category_name, t.transaction_type, t.description, t.created_at, t.updated_at, t.transaction_date
+		SELECT t.id, t.user_id, t.amount, c.name as category_name, t.transaction_type, t.description, t.created_at, t.updated_at, t.transaction_date,
+			` + anomalyColumns + `
 		FROM individual_transactions t
 		JOIN categories c ON t.category_id = c.id
+		LEFT JOIN transaction_anomalies a ON a.transaction_id = t.id
 		WHERE t.user_id = $1
 	`
-	args := []interface{}{userID}
+	b := newQueryBuilder(userID)
+	filter.apply(b)
 
-	if filter.StartDate != "" {
-		query += " AND t.created_at >= $2"
-		args = append(args, filter.StartDate)
+	if filter.SortBy == "" {
+		filter.SortBy = "date"
+	}
+	if filter.SortOrder == "" {
+		filter.SortOrder = "desc"
 	}
-	if filter.EndDate != "" {
-		query += " AND t.created_at <= $3"
-		args = append(args, filter.EndDate)
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultTransactionsPageSize
 	}
-	if filter.TransactionType != "" {
-		query += " AND t.transaction_type = $4"
-		args = append(args, filter.TransactionType)
+	if filter.Limit > MaxTransactionsPageSize {
+		filter.Limit = MaxTransactionsPageSize
+	}
+	sortColumn, ok := transactionSortColumns[filter.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort: %s", filter.SortBy)
+	}
+	comparison, direction := "<", "DESC"
+	if filter.SortOrder == "asc" {
+		comparison, direction = ">", "ASC"
 	}
 
+	if filter.Cursor != "" {
+		cursor, err := decodeTransactionCursor(filter.Cursor, filter.SortBy, filter.SortOrder)
+		if err != nil {
+			return nil, err
+		}
+		b.where(fmt.Sprintf("(%s, t.id) %s (?, ?)", sortColumn, comparison), cursor.Value, cursor.ID)
+	}
+	query += b.and()
+	// fetch one extra row to know whether there is a next page
+	query += fmt.Sprintf(" ORDER BY %s %s, t.id %s LIMIT %s", sortColumn, direction, direction, b.arg(filter.Limit+1))
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	rows, err := t.db.QueryContext(ctx, query, args...)
+	rows, err := t.db.QueryContext(ctx, query, b.args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var transactions []Transaction
+	page := &TransactionPage{Transactions: []Transaction{}}
 	for rows.Next() {
 		transaction := &Transaction{}
-		err := rows.Scan(&transaction.ID, &transaction.UserID, &transaction.Amount,
+		var flag nullAnomaly
+		dest := []any{&transaction.ID, &transaction.UserID, &transaction.Amount,
 			&transaction.CategoryName, &transaction.TransactionType, &transaction.Description, &transaction.CreatedAt,
-			&transaction.UpdatedAt, &transaction.TransactionDate)
+			&transaction.UpdatedAt, &transaction.TransactionDate}
+		err := rows.Scan(append(dest, flag.dest()...)...)
 		if err != nil {
 			return nil, err
 		}
-		transactions = append(transactions, *transaction)
+		transaction.Anomaly = flag.anomaly()
+		page.Transactions = append(page.Transactions, *transaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Transactions) > filter.Limit {
+		page.Transactions = page.Transactions[:filter.Limit]
+		last := page.Transactions[len(page.Transactions)-1]
+		cursor := transactionCursor{SortBy: filter.SortBy, SortOrder: filter.SortOrder, ID: last.ID}
+		switch filter.SortBy {
+		case "amount":
+			cursor.Value = strconv.FormatFloat(last.Amount, 'f', 2, 64)
+		default:
+			cursor.Value = last.TransactionDate
+		}
+		page.NextCursor = encodeTransactionCursor(cursor)
 	}
 
-	return transactions, nil
+	return page, nil
 }
 
 func (t *TransactionStore) GetByID(ctx context.Context, transactionID int64) (*Transaction, error) {
 	query := `
-		SELECT id, user_id, amount, category_id, transaction_type, description,created_at, updated_at, transaction_date
-		FROM individual_transactions
-		WHERE id = $1
+		SELECT t.id, t.user_id, t.amount, t.category_id, t.transaction_type, t.description, t.created_at, t.updated_at, t.transaction_date,
+			` + anomalyColumns + `
+		FROM individual_transactions t
+		LEFT JOIN transaction_anomalies a ON a.transaction_id = t.id
+		WHERE t.id = $1
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
 	transaction := &Transaction{}
-	err := t.db.QueryRowContext(ctx, query, transactionID).Scan(
+	var flag nullAnomaly
+	dest := []any{
 		&transaction.ID,
 		&transaction.UserID,
 		&transaction.Amount,
@@ -123,13 +344,15 @@ func (t *TransactionStore) GetByID(ctx context.Context, transactionID int64) (*T
 		&transaction.CreatedAt,
 		&transaction.UpdatedAt,
 		&transaction.TransactionDate,
-	)
+	}
+	err := t.db.QueryRowContext(ctx, query, transactionID).Scan(append(dest, flag.dest()...)...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
 		}
 		return nil, err
 	}
+	transaction.Anomaly = flag.anomaly()
 
 	return transaction, nil
 }
@@ -187,3 +410,57 @@ func (t *TransactionStore) Update(ctx context.Context, transaction *Transaction)
 	}
 	return nil
 }
+
+// Export calls fn for every transaction of the user matching the filter, oldest
+// first. Rows are read through a server-side cursor in batches, so exports of
+// any size use constant memory. Paging fields of the filter are ignored.
+func (t *TransactionStore) Export(ctx context.Context, userID int64, filter ListTransactionsByUserFilter, fn func(*Transaction) error) error {
+	query := `
+		DECLARE transactions_export NO SCROLL CURSOR FOR
+		SELECT t.id, t.user_id, t.amount, t.category_id, c.name, t.transaction_type, COALESCE(t.description, ''),
+			t.transaction_date, t.created_at, t.updated_at
+		FROM individual_transactions t
+		JOIN categories c ON t.category_id = c.id
+		WHERE t.user_id = $1
+	`
+	b := newQueryBuilder(userID)
+	filter.apply(b)
+	query += b.and() + " ORDER BY t.transaction_date, t.id"
+
+	return withTx(t.db, ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, query, b.args...); err != nil {
+			return err
+		}
+
+		fetch := fmt.Sprintf("FETCH %d FROM transactions_export", exportFetchSize)
+		for {
+			rows, err := tx.QueryContext(ctx, fetch)
+			if err != nil {
+				return err
+			}
+
+			fetched := 0
+			for rows.Next() {
+				fetched++
+				transaction := &Transaction{}
+				err := rows.Scan(&transaction.ID, &transaction.UserID, &transaction.Amount, &transaction.CategoryID,
+					&transaction.CategoryName, &transaction.TransactionType, &transaction.Description,
+					&transaction.TransactionDate, &transaction.CreatedAt, &transaction.UpdatedAt)
+				if err == nil {
+					err = fn(transaction)
+				}
+				if err != nil {
+					rows.Close()
+					return err
+				}
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			if fetched < exportFetchSize {
+				return nil
+			}
+		}
+	})
+}
@@ -94,7 +94,8 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 
 func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 	query := `
-		SELECT users.id, username, email, password, created_at, roles.*
+		SELECT users.id, username, email, password, created_at, role_id,
+			roles.id, roles.name, roles.level, COALESCE(roles.description, '')
 		FROM users
 		JOIN roles ON (users.role_id = roles.id)
 		WHERE users.id = $1 AND is_active = true
@@ -114,6 +115,7 @@ func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 		&user.Email,
 		&user.Password.hash,
 		&user.CreatedAt,
+		&user.RoleID,
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Level,
@@ -274,7 +276,10 @@ func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, id int64) error {
 
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT id, username, email, password, created_at FROM users
+		SELECT users.id, username, email, password, created_at, role_id,
+			roles.id, roles.name, roles.level, COALESCE(roles.description, '')
+		FROM users
+		JOIN roles ON (users.role_id = roles.id)
 		WHERE email = $1 AND is_active = true
 	`
 
@@ -290,6 +295,11 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 		&user.Email,
 		&user.Password.hash,
 		&user.CreatedAt,
+		&user.RoleID,
+		&user.Role.ID,
+		&user.Role.Name,
+		&user.Role.Level,
+		&user.Role.Description,
 	)
 	if err != nil {
 		switch err {
@@ -303,6 +313,27 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 	return user, nil
 }
 
+// UpdateRole gives the user another role.
+func (s *UserStore) UpdateRole(ctx context.Context, userID, roleID int64) error {
+	query := `UPDATE users SET role_id = $2 WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, roleID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 func (s *UserStore) CreateUserResetPasswordToken(ctx context.Context, userID int64, token string, exp time.Duration) error {
 	query := `INSERT INTO reset_password (user_id, token, expires_at) VALUES ($1, $2, $3)`
 
@@ -415,7 +446,9 @@ func (s *UserStore) ResetPassword(ctx context.Context, token string, newPassword
 		if err := s.updateTokenActiveStatus(ctx, tx, token, false); err != nil {
 			return err
 		}
-		return nil
+		// 4. sign out everywhere, whoever knew the old password included
+		_, err = revokeSessions(ctx, tx, "user_id = $1", user.ID)
+		return err
 	})
 }
 
@@ -0,0 +1,104 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 secret of the RFC 6238 Appendix B test vectors.
+const rfcSecret = "12345678901234567890"
+
+// rfcVectors are the Appendix B SHA-1 codes, cut down to their last 6 digits.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestHOTP(t *testing.T) {
+	for _, v := range rfcVectors {
+		step := v.unix / int64(Period/time.Second)
+		if got := hotp([]byte(rfcSecret), step); got != v.code {
+			t.Errorf("hotp(step %d) = %s, want %s", step, got, v.code)
+		}
+	}
+}
+
+func TestCode(t *testing.T) {
+	secret := encoding.EncodeToString([]byte(rfcSecret))
+	for _, v := range rfcVectors {
+		got, err := Code(secret, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatalf("Code(T=%d): %v", v.unix, err)
+		}
+		if got != v.code {
+			t.Errorf("Code(T=%d) = %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestCodePaddedSecret(t *testing.T) {
+	// authenticator exports sometimes keep the padding and use lower case
+	secret := base32.StdEncoding.EncodeToString([]byte("1234567890"))
+	want, err := Code(secret, time.Unix(59, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Code(strings.ToLower(secret), time.Unix(59, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("Code with lower case secret = %s, want %s", got, want)
+	}
+}
+
+func TestVerifySkew(t *testing.T) {
+	secret := encoding.EncodeToString([]byte(rfcSecret))
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	for i := -Skew - 1; i <= Skew+1; i++ {
+		code, err := Code(secret, now.Add(time.Duration(i)*Period))
+		if err != nil {
+			t.Fatal(err)
+		}
+		matched, ok := Verify(secret, code, now)
+		inWindow := i >= -Skew && i <= Skew
+		if ok != inWindow {
+			t.Errorf("Verify(code of step %+d) = %v, want %v", i, ok, inWindow)
+			continue
+		}
+		if ok && matched != step+int64(i) {
+			t.Errorf("Verify(code of step %+d) matched step %d, want %d", i, matched, step+int64(i))
+		}
+	}
+}
+
+func TestVerifyCodeLength(t *testing.T) {
+	secret := encoding.EncodeToString([]byte(rfcSecret))
+	now := time.Unix(59, 0)
+
+	for _, code := range []string{"", "28708", "2870820", "94287082", "0287082"} {
+		if _, ok := Verify(secret, code, now); ok {
+			t.Errorf("Verify(%q) accepted a code of length %d", code, len(code))
+		}
+	}
+	if _, ok := Verify(secret, "287 082", now); !ok {
+		t.Error("Verify rejected a code typed with a space")
+	}
+}
+
+func TestVerifyInvalidSecret(t *testing.T) {
+	if _, ok := Verify("not base32!", "287082", time.Unix(59, 0)); ok {
+		t.Error("Verify accepted a code for an invalid secret")
+	}
+}
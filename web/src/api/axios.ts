@@ -1,5 +1,5 @@
 import axios from 'axios';
-import { getToken, removeToken } from '../utils/token';
+import { getRefreshToken, getToken, removeToken, setRefreshToken, setToken } from '../utils/token';
 
 const apiUrl = import.meta.env.VITE_API_URL;
 console.log('API URL:', apiUrl);
@@ -19,10 +19,40 @@ api.interceptors.request.use((config) => {
   return config;
 });
 
-// Handle unauthorized responses globally
+// Refresh tokens rotate on every use, so concurrent 401s share one refresh
+let refreshing: Promise<string> | null = null;
+
+const refreshAccessToken = () => {
+  if (!refreshing) {
+    refreshing = axios
+      .post(`${apiUrl}/auth/refresh`, { refreshToken: getRefreshToken() })
+      .then((res) => {
+        setToken(res.data.token);
+        setRefreshToken(res.data.refreshToken);
+        return res.data.token as string;
+      })
+      .finally(() => {
+        refreshing = null;
+      });
+  }
+  return refreshing;
+};
+
+// Renew the access token once on 401, otherwise send the user to login
 api.interceptors.response.use(
   (response) => response,
-  (error) => {
+  async (error) => {
+    const request = error.config;
+    if (error.response?.status === 401 && getRefreshToken() && request && !request._retried) {
+      request._retried = true;
+      try {
+        const token = await refreshAccessToken();
+        request.headers.Authorization = `Bearer ${token}`;
+        return api(request);
+      } catch {
+        // fall through to logging out
+      }
+    }
     if (error.response?.status === 401) {
       removeToken();
       window.location.href = '/login';
@@ -16,7 +16,10 @@ export type User = {
 type AuthContextType = {
   user: User | null;
   loading: boolean;
-  login: (email: string, password: string) => Promise<void>;
+  login: (email: string, password: string) => Promise<{ mfaRequired: boolean }>;
+  verifyMfa: (code: string, isRecoveryCode?: boolean) => Promise<void>;
+  cancelMfa: () => void;
+  mfaPending: boolean;
   register: (name: string, email: string, password: string) => Promise<void>;
   logout: () => void;
   isAuthenticated: boolean;
@@ -26,7 +29,10 @@ type AuthContextType = {
 const AuthContext = createContext<AuthContextType>({
   user: null,
   loading: true,
-  login: async () => {},
+  login: async () => ({ mfaRequired: false }),
+  verifyMfa: async () => {},
+  cancelMfa: () => {},
+  mfaPending: false,
   register: async () => {},
   logout: () => {},
   isAuthenticated: false,
@@ -37,6 +43,8 @@ export const useAuth = () => useContext(AuthContext);
 export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children }) => {
   const [user, setUser] = useState<User | null>(null);
   const [token, setToken] = useState<string | null>(null);
+  // Challenge token of a login waiting for its second factor
+  const [mfaChallenge, setMfaChallenge] = useState<string | null>(null);
   const [loading, setLoading] = useState(false);
   const { toast } = useToast();
 
@@ -50,33 +58,45 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
     }
   }, []);
 
-  // Login function
+  // Store the tokens of a finished login and load the user they belong to
+  const completeLogin = async (tokens: { token: string; refreshToken: string }) => {
+    const user = await api.get('/users/token', {
+      headers: {
+        Authorization: `Bearer ${tokens.token}`,
+      }
+    })
+    const userDetails = {
+      id: user.data.user.id,
+      name: user.data.user.username,
+      email: user.data.user.email,
+      roleId: user.data.user.role_id,
+    };
+    // Save user to localStorage
+    localStorage.setItem('user', JSON.stringify(userDetails));
+    localStorage.setItem('access_token', tokens.token);
+    localStorage.setItem('refresh_token', tokens.refreshToken);
+    setToken(tokens.token);
+    setUser(userDetails);
+
+    toast({
+      title: "Login successful",
+      description: "Welcome back!",
+    });
+  };
+
+  // Login function. Users with two-factor authentication get a challenge
+  // instead of tokens, which verifyMfa completes.
   const login = async (email: string, password: string) => {
     try {
       setLoading(true);      
-      const token = await api.post('/auth/login', { email, password });
-      const user = await api.get('/users/token', {
-        headers: {
-          Authorization: `Bearer ${token.data.token}`,
-        }
-      })
-      const userDetails = {
-        id: user.data.user.id,
-        name: user.data.user.username,
-        email: user.data.user.email,
-        roleId: user.data.user.role_id,
-      };
-      // Save user to localStorage
-      localStorage.setItem('user', JSON.stringify(userDetails));
-      localStorage.setItem('access_token', token.data.token);
-      setToken(token.data.token);
-      setUser(userDetails);
-      
-      toast({
-        title: "Login successful",
-        description: "Welcome back!",
-      });
+      const response = await api.post('/auth/login', { email, password });
+      if (response.data.mfaRequired) {
+        setMfaChallenge(response.data.challengeToken);
+        return { mfaRequired: true };
+      }
+      await completeLogin(response.data);
       setLoading(false);
+      return { mfaRequired: false };
     } catch (error) {
       toast({
         title: "Login failed",
@@ -90,6 +110,38 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
     }
   };
 
+  // Finish a two-factor login with a code from the authenticator app or a
+  // recovery code. A challenge is only good for one try, so a failed attempt
+  // means signing in with the password again.
+  const verifyMfa = async (code: string, isRecoveryCode = false) => {
+    if (!mfaChallenge) {
+      throw new Error("Sign in with your password first");
+    }
+    try {
+      setLoading(true);
+      const response = await api.post('/auth/mfa', {
+        challengeToken: mfaChallenge,
+        ...(isRecoveryCode ? { recoveryCode: code } : { code }),
+      });
+      setMfaChallenge(null);
+      await completeLogin(response.data);
+    } catch (error) {
+      setMfaChallenge(null);
+      toast({
+        title: "Verification failed",
+        description: `${error.response?.data?.error || "An error occurred"}, please sign in again`,
+        variant: "destructive",
+      });
+      throw error;
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const cancelMfa = () => {
+    setMfaChallenge(null);
+  };
+
   // Register function
   const register = async (name: string, email: string, password: string) => {
     try {
@@ -115,8 +167,16 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
 
   // Logout function
   const logout = () => {
+    // end the session server side too, the tokens are dropped either way
+    const accessToken = localStorage.getItem('access_token');
+    if (accessToken) {
+      api.delete('/sessions/current', {
+        headers: { Authorization: `Bearer ${accessToken}` },
+      }).catch(() => {});
+    }
     localStorage.removeItem('user');
     localStorage.removeItem('access_token');
+    localStorage.removeItem('refresh_token');
     setUser(null);
     toast({
       title: "Logged out",
@@ -129,6 +189,9 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
     user,
     loading,
     login,
+    verifyMfa,
+    cancelMfa,
+    mfaPending: !!mfaChallenge,
     register,
     logout,
     isAuthenticated: !!token && !!user,
@@ -52,6 +52,8 @@ type TransactionContextType = {
   updateGroup: (id: string, data: Partial<TransactionGroup>) => void;
   deleteGroup: (id: string) => void;
   getGroupById: (id: string) => TransactionGroup | undefined;
+  hasMoreTransactions: boolean;
+  loadMoreTransactions: () => Promise<void>;
   isLoading: boolean;
 };
 
@@ -69,17 +71,45 @@ const TransactionContext = createContext<TransactionContextType>({
   updateGroup: () => {},
   deleteGroup: () => {},
   getGroupById: () => undefined,
+  hasMoreTransactions: false,
+  loadMoreTransactions: async () => {},
   isLoading: false,
 });
 
 export const useTransactions = () => useContext(TransactionContext);
 
+// How many transactions are fetched per page
+const TRANSACTIONS_PAGE_SIZE = 50;
+
+const toTransaction = (transaction: any): Transaction => ({
+  id: transaction.id,
+  amount: transaction.amount,
+  description: transaction.description,
+  category: transaction.categoryName,
+  date: transaction.transactionDate,
+  createdAt: transaction.createdAt,
+  type: transaction.transactionType,
+  groupId: transaction.groupId,
+  createdBy: transaction.userId,
+});
+
+const fetchTransactionsPage = async (cursor: string) => {
+  const page = await api.get(`/transactions`, {
+    params: { limit: TRANSACTIONS_PAGE_SIZE, ...(cursor && { cursor }) },
+  });
+  return {
+    transactions: (page.data?.transactions ?? []).map(toTransaction),
+    nextCursor: (page.data?.next_cursor ?? "") as string,
+  };
+};
+
 export const TransactionProvider: React.FC<{ children: React.ReactNode }> = ({
   children,
 }) => {
   const [transactions, setTransactions] = useState<Transaction[]>([]);
   const [categories, setCategories] = useState<Categories[]>([]);
   const [groups, setGroups] = useState<TransactionGroup[]>([]);
+  const [nextCursor, setNextCursor] = useState("");
   const [isLoading, setIsLoading] = useState(false);
   const { user } = useAuth();
 
@@ -90,25 +120,11 @@ export const TransactionProvider: React.FC<{ children: React.ReactNode }> = ({
       if (!getToken()) return;
       try {
         setIsLoading(true);
-        const loadedTransactions = await api.get(`/transactions`);
-        if (!loadedTransactions.data) {
-          setIsLoading(false);
-          return;
-        }
-        const transactions: Transaction[] = loadedTransactions.data.map(
-          (transaction: any) => ({
-            id: transaction.id,
-            amount: transaction.amount,
-            description: transaction.description,
-            category: transaction.categoryName,
-            date: transaction.transactionDate,
-            createdAt: transaction.createdAt,
-            type: transaction.transactionType,
-            groupId: transaction.groupId,
-            createdBy: transaction.userId,
-          })
-        );
-        setTransactions(transactions);
+        // The API is cursor paginated, only the first page is loaded here and
+        // the rest on demand through loadMoreTransactions
+        const page = await fetchTransactionsPage("");
+        setTransactions(page.transactions);
+        setNextCursor(page.nextCursor);
         setIsLoading(false);
       } catch (error) {
         setIsLoading(false);
@@ -123,6 +139,29 @@ export const TransactionProvider: React.FC<{ children: React.ReactNode }> = ({
     loadTransactions();
   }, [user]);
 
+  // Load the next page of transactions
+  const loadMoreTransactions = async () => {
+    if (!nextCursor || isLoading) return;
+    try {
+      setIsLoading(true);
+      const page = await fetchTransactionsPage(nextCursor);
+      setTransactions((prev) => {
+        const loaded = new Set(prev.map((t) => t.id));
+        return [...prev, ...page.transactions.filter((t) => !loaded.has(t.id))];
+      });
+      setNextCursor(page.nextCursor);
+      setIsLoading(false);
+    } catch (error) {
+      setIsLoading(false);
+      toast({
+        title: "Error loading transactions",
+        description: error.response?.data?.error || error.message,
+        variant: "destructive",
+      });
+      console.error("Failed to load more transactions", error);
+    }
+  };
+
   // Load categories
   useEffect(() => {
     const loadCategories = async () => {
@@ -131,7 +170,11 @@ export const TransactionProvider: React.FC<{ children: React.ReactNode }> = ({
         if (!getToken()) return;
         setIsLoading(true);
         const loadedCategories = await api.get("/categories");
-        const categories: Categories[] = loadedCategories.data.map(
+        // Categories come back as a tree, flatten it so both parents and
+        // sub-categories can be picked
+        const flatten = (nodes: any[]): any[] =>
+          nodes.flatMap((node) => [node, ...flatten(node.children ?? [])]);
+        const categories: Categories[] = flatten(loadedCategories.data ?? []).map(
           (category: any) => ({
             id: category.id,
             name: category.name,
@@ -362,6 +405,8 @@ export const TransactionProvider: React.FC<{ children: React.ReactNode }> = ({
     updateGroup,
     deleteGroup,
     getGroupById,
+    hasMoreTransactions: nextCursor !== "",
+    loadMoreTransactions,
     isLoading,
   };
 
@@ -1,5 +1,6 @@
-import { useState, useMemo } from "react";
+import { useState, useEffect } from "react";
 import { useTransactions } from "@/context/TransactionContext";
+import api from "@/api/axios";
 import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card";
 import {
   LineChart,
@@ -16,14 +17,7 @@ import {
   Legend,
   ResponsiveContainer,
 } from "recharts";
-import {
-  format,
-  subMonths,
-  startOfMonth,
-  addDays,
-  getMonth,
-  getYear,
-} from "date-fns";
+import { format } from "date-fns";
 import MonthPicker from "@/components/MonthPicker";
 import TabView from "@/components/TabView";
 import {
@@ -45,164 +39,70 @@ const COLORS = [
   "#ffc658",
   "#8dd1e1",
 ];
-const Analytics = () => {
-  const {
-    transactions,
-    getTransactionsByMonth,
-    groups,
-    getGroupTransactions,
-    getPersonalTransactions,
-  } = useTransactions();
-  const [currentDate, setCurrentDate] = useState(new Date());
-  const [selectedGroupId, setSelectedGroupId] = useState<string | null>(null);
-
-  // Get transactions for current month
-  const currentMonth = currentDate.getMonth();
-  const currentYear = currentDate.getFullYear();
-  const monthlyTransactions = getTransactionsByMonth(currentMonth, currentYear);
-
-  // Get personal transactions
-  const personalTransactions = getPersonalTransactions();
-
-  // Get group transactions
-  const groupTransactions = selectedGroupId
-    ? getGroupTransactions(selectedGroupId)
-    : transactions.filter((t) => t.groupId);
-
-  // For pie chart - Expense by category
-  const getCategoryData = (transactionsList) => {
-    return transactionsList
-      .filter((t) => t.type === "expense")
-      .reduce(
-        (acc, transaction) => {
-          const existingCategory = acc.find(
-            (item) => item.name === transaction.category
-          );
-          if (existingCategory) {
-            existingCategory.value += transaction.amount;
-          } else {
-            acc.push({
-              name: transaction.category,
-              value: transaction.amount,
-            });
-          }
-          return acc;
-        },
-        [] as {
-          name: string;
-          value: number;
-        }[]
-      )
-      .sort((a, b) => b.value - a.value);
-  };
-
-  // Helper to filter transactions by month and year
-  const filterByMonthYear = (transactionsList, month, year) =>
-    transactionsList.filter((t) => {
-      const d = new Date(t.date);
-      return d.getMonth() === month && d.getFullYear() === year;
-    });
-  // Get filtered transactions for the selected month/year
-  const personalMonthlyTransactions = useMemo(
-    () => filterByMonthYear(personalTransactions, currentMonth, currentYear),
-    [personalTransactions, currentMonth, currentYear]
-  );
-  const groupMonthlyTransactions = useMemo(
-    () => filterByMonthYear(groupTransactions, currentMonth, currentYear),
-    [groupTransactions, currentMonth, currentYear]
-  );
-
-  // Expense by category data (for selected month)
-  const personalCategoryData = useMemo(
-    () => getCategoryData(personalMonthlyTransactions),
-    [personalMonthlyTransactions]
-  );
-  const groupCategoryData = useMemo(
-    () => getCategoryData(groupMonthlyTransactions),
-    [groupMonthlyTransactions]
-  );
 
-  // For line chart - Monthly spending trend (last 6 months)
-  const getMonthlyTrend = (transactionsList) => {
-    const now = new Date();
-    const data = [];
-
-    // Generate data for the last 6 months
-    for (let i = 5; i >= 0; i--) {
-      const monthDate = subMonths(now, i);
-      const month = getMonth(monthDate);
-      const year = getYear(monthDate);
+type TrendPoint = {
+  month: string;
+  period: string;
+  income: number;
+  expense: number;
+  savings: number;
+};
+type CategoryPoint = { name: string; value: number };
+type DailyPoint = { day: number; expense: number };
 
-      // Filter transactions for this month
-      const monthTransactions = transactionsList.filter((t) => {
-        const date = new Date(t.date);
-        return getMonth(date) === month && getYear(date) === year;
-      });
+// Loads the monthly trend (last 6 months up to month), the expenses by
+// category and the daily spending of month for a personal or group scope
+const useAnalytics = (month: string, params: Record<string, string>) => {
+  const [trend, setTrend] = useState<TrendPoint[]>([]);
+  const [byCategory, setByCategory] = useState<CategoryPoint[]>([]);
+  const [daily, setDaily] = useState<DailyPoint[]>([]);
+  const paramsKey = JSON.stringify(params);
 
-      // Calculate total expenses and income
-      const expenses = monthTransactions
-        .filter((t) => t.type === "expense")
-        .reduce((sum, t) => sum + t.amount, 0);
-      const income = monthTransactions
-        .filter((t) => t.type === "income")
-        .reduce((sum, t) => sum + t.amount, 0);
-      data.push({
-        month: format(monthDate, "MMM"),
-        expense: expenses,
-        income: income,
-        savings: income - expenses,
-      });
-    }
-    return data;
-  };
+  useEffect(() => {
+    let cancelled = false;
+    const query = { ...JSON.parse(paramsKey), month };
+    Promise.all([
+      api.get("/analytics/trend", { params: { ...query, months: 6 } }),
+      api.get("/analytics/by-category", { params: query }),
+      api.get("/analytics/daily", { params: query }),
+    ])
+      .then(([trendRes, categoryRes, dailyRes]) => {
+        if (cancelled) return;
+        setTrend(trendRes.data);
+        setByCategory(categoryRes.data);
+        setDaily(dailyRes.data);
+      })
+      .catch((error) => console.error("Failed to load analytics", error));
+    return () => {
+      cancelled = true;
+    };
+  }, [month, paramsKey]);
 
-  // Monthly trend data
-  const personalMonthlyTrend = useMemo(
-    () => getMonthlyTrend(personalTransactions),
-    [personalTransactions]
-  );
-  const groupMonthlyTrend = useMemo(
-    () => getMonthlyTrend(groupTransactions),
-    [groupTransactions]
-  );
+  return { trend, byCategory, daily };
+};
 
-  // For bar chart - Daily spending (current month)
-  const getDailySpending = (transactionsList) => {
-    const startOfCurrentMonth = startOfMonth(currentDate);
-    const daysInMonth = new Date(currentYear, currentMonth + 1, 0).getDate();
-    const data = [];
-    for (let day = 1; day <= daysInMonth; day++) {
-      const currentDay = addDays(startOfCurrentMonth, day - 1);
+const Analytics = () => {
+  const { groups } = useTransactions();
+  const [currentDate, setCurrentDate] = useState(new Date());
+  const [selectedGroupId, setSelectedGroupId] = useState<string | null>(null);
 
-      // Filter transactions for this day
-      const dayTransactions = transactionsList.filter((t) => {
-        const date = new Date(t.date);
-        return (
-          date.getDate() === day &&
-          date.getMonth() === currentMonth &&
-          date.getFullYear() === currentYear
-        );
-      });
-      const expenses = dayTransactions
-        .filter((t) => t.type === "expense")
-        .reduce((sum, t) => sum + t.amount, 0);
-      data.push({
-        day: day,
-        expense: expenses,
-      });
-    }
-    return data;
-  };
+  // Charts are computed by the API; the month and selected group pick the data
+  const month = format(currentDate, "yyyy-MM");
+  const personalAnalytics = useAnalytics(month, { scope: "personal" });
+  // groups that only exist locally have no server ID and fall back to all groups
+  const groupAnalytics = useAnalytics(month, {
+    scope: "group",
+    ...(selectedGroupId && /^\d+$/.test(selectedGroupId)
+      ? { groupId: selectedGroupId }
+      : {}),
+  });
 
-  // Daily spending data
-  const personalDailySpending = useMemo(
-    () => getDailySpending(personalTransactions),
-    [personalTransactions, currentMonth, currentYear]
-  );
-  const groupDailySpending = useMemo(
-    () => getDailySpending(groupTransactions),
-    [groupTransactions, currentMonth, currentYear]
-  );
+  const personalMonthlyTrend = personalAnalytics.trend;
+  const personalCategoryData = personalAnalytics.byCategory;
+  const personalDailySpending = personalAnalytics.daily;
+  const groupMonthlyTrend = groupAnalytics.trend;
+  const groupCategoryData = groupAnalytics.byCategory;
+  const groupDailySpending = groupAnalytics.daily;
 
   // Group filter selector
   const groupFilterSelect = (
@@ -13,8 +13,10 @@ const LoginPage = () => {
   const [isSubmitting, setIsSubmitting] = useState(false);
   const [showPassword, setShowPassword] = useState(false);
   const [error, setError] = useState<string | null>(null);
+  const [mfaCode, setMfaCode] = useState("");
+  const [useRecoveryCode, setUseRecoveryCode] = useState(false);
 
-  const { login, isAuthenticated } = useAuth();
+  const { login, verifyMfa, cancelMfa, mfaPending, isAuthenticated } = useAuth();
 
   // If already authenticated, redirect to dashboard
   if (isAuthenticated) {
@@ -34,6 +36,7 @@ const LoginPage = () => {
       }
 
       await login(email, password);
+      setMfaCode("");
     } catch (error) {
       if (error instanceof Error) {
         setError(error.message);
@@ -45,6 +48,90 @@ const LoginPage = () => {
     }
   };
 
+  const handleMfaSubmit = async (e: FormEvent<HTMLFormElement>) => {
+    e.preventDefault();
+    if (isSubmitting) return;
+
+    setError(null);
+    setIsSubmitting(true);
+
+    try {
+      await verifyMfa(mfaCode.trim(), useRecoveryCode);
+    } catch (error) {
+      // the challenge is spent, so the password has to be entered again
+      setPassword("");
+      setError("Verification failed, please sign in again");
+    } finally {
+      setMfaCode("");
+      setIsSubmitting(false);
+    }
+  };
+
+  const handleMfaCancel = () => {
+    cancelMfa();
+    setPassword("");
+    setMfaCode("");
+    setError(null);
+  };
+
+  const mfaForm = (
+    <form className="space-y-6" onSubmit={handleMfaSubmit}>
+      <div className="space-y-2">
+        <Label htmlFor="mfa-code">
+          {useRecoveryCode ? "Recovery code" : "Authentication code"}
+        </Label>
+        <Input
+          id="mfa-code"
+          type="text"
+          inputMode={useRecoveryCode ? "text" : "numeric"}
+          autoComplete="one-time-code"
+          placeholder={useRecoveryCode ? "abcde-fghij" : "123456"}
+          value={mfaCode}
+          onChange={(e) => setMfaCode(e.target.value)}
+          maxLength={useRecoveryCode ? 20 : 10}
+          autoFocus
+          required
+        />
+        <p className="text-sm text-muted-foreground">
+          {useRecoveryCode
+            ? "Enter one of the recovery codes you saved when you turned on two-factor authentication."
+            : "Enter the code from your authenticator app."}
+        </p>
+      </div>
+
+      <Button type="submit" className="w-full" disabled={isSubmitting}>
+        {isSubmitting ? (
+          <>
+            <span className="animate-spin mr-2">◌</span>
+            Verifying...
+          </>
+        ) : (
+          "Verify"
+        )}
+      </Button>
+
+      <div className="flex items-center justify-between text-sm">
+        <button
+          type="button"
+          className="text-primary hover:underline"
+          onClick={() => {
+            setUseRecoveryCode(!useRecoveryCode);
+            setMfaCode("");
+          }}
+        >
+          {useRecoveryCode ? "Use authenticator code" : "Use a recovery code"}
+        </button>
+        <button
+          type="button"
+          className="text-muted-foreground hover:underline"
+          onClick={handleMfaCancel}
+        >
+          Back to sign in
+        </button>
+      </div>
+    </form>
+  );
+
   return (
     <>
       <div className="min-h-screen flex items-center justify-center px-4 py-12 bg-muted/30">
@@ -52,9 +139,10 @@ const LoginPage = () => {
           <div className="text-center">
             <h1 className="text-3xl font-bold text-primary">FinTracker</h1>
             <p className="mt-2 text-muted-foreground">
-              Sign in to your account
+              {mfaPending ? "Two-factor authentication" : "Sign in to your account"}
             </p>
           </div>
+          {mfaPending ? mfaForm : (
           <form className="space-y-6" onSubmit={handleSubmit}>
             <div className="space-y-2">
               <Label htmlFor="email">Email</Label>
@@ -112,6 +200,7 @@ const LoginPage = () => {
               )}
             </Button>
           </form>
+          )}
 
           <p className="text-center text-sm text-muted-foreground">
             Don't have an account?{" "}
@@ -30,7 +30,10 @@ const Transactions = () => {
     getTransactionsByMonth,
     getPersonalTransactions,
     getGroupTransactions,
-    groups
+    groups,
+    hasMoreTransactions,
+    loadMoreTransactions,
+    isLoading
   } = useTransactions();
   const [currentDate, setCurrentDate] = useState(new Date());
   const [searchTerm, setSearchTerm] = useState('');
@@ -286,6 +289,15 @@ const Transactions = () => {
     );
   };
 
+  // Older transactions are fetched a page at a time
+  const loadMoreButton = hasMoreTransactions && (
+    <div className="flex justify-center pt-2">
+      <Button variant="outline" onClick={loadMoreTransactions} disabled={isLoading}>
+        Load more transactions
+      </Button>
+    </div>
+  );
+
   // Handle tab change
   const handleTabChange = (value: string) => {
     setActiveTab(value);
@@ -298,6 +310,7 @@ const Transactions = () => {
       
       <div className="space-y-4">
         {renderTransactionsList(filteredPersonalTransactions)}
+        {loadMoreButton}
       </div>
     </div>
   );
@@ -310,6 +323,7 @@ const Transactions = () => {
       
       <div className="space-y-4">
         {renderTransactionsList(filteredGroupTransactions)}
+        {loadMoreButton}
       </div>
     </div>
   );
@@ -1,10 +1,18 @@
 const TOKEN_KEY = 'access_token';
+const REFRESH_TOKEN_KEY = 'refresh_token';
 
 export const getToken = () => localStorage.getItem(TOKEN_KEY);
 
 export const setToken = (token: string) => localStorage.setItem(TOKEN_KEY, token);
 
-export const removeToken = () => localStorage.removeItem(TOKEN_KEY);
+export const removeToken = () => {
+  localStorage.removeItem(TOKEN_KEY);
+  localStorage.removeItem(REFRESH_TOKEN_KEY);
+};
+
+export const getRefreshToken = () => localStorage.getItem(REFRESH_TOKEN_KEY);
+
+export const setRefreshToken = (token: string) => localStorage.setItem(REFRESH_TOKEN_KEY, token);
 
 export const isTokenExpired = () => {
   const token = getToken();
@@ -13,4 +21,4 @@ export const isTokenExpired = () => {
   const payload = JSON.parse(atob(token.split('.')[1]));
   const exp = payload.exp * 1000; // Convert to milliseconds
   return Date.now() > exp;
-};
\ No newline at end of file
+};